@@ -0,0 +1,147 @@
+// Package api はゲームサーバの API (move / start / join と、ビジュアライザが使う data / event) のクライアントと、
+// リクエストとレスポンスの型を提供する
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 1 面の大きさ (game.N と同じ)
+const fieldSize = 5
+
+// レスポンスの status
+type Status string
+
+const (
+	StatusOk           Status = "ok"
+	StatusAlreadyMoved Status = "already_moved"
+	StatusGameFinished Status = "game_finished"
+	// 練習試合が既に開始している (start API)
+	StatusStarted Status = "started"
+	// 前回の実行から 1 秒以内に実行した (join API)
+	StatusErrorTimeLimit Status = "error_time_limit"
+)
+
+// 練習試合のモード
+const (
+	ModeStandStill = 0 // 他のagentは移動しない
+	ModeRandom     = 1 // 他のagentはランダムに移動する
+)
+
+// 移動APIのレスポンス
+type MoveResponse struct {
+	Status  Status      `json:"status"`
+	Now     int64       `json:"now"`
+	Turn    int         `json:"turn"`
+	Move    []int       `json:"move"`
+	Score   []int       `json:"score"`
+	Field   [][][][]int `json:"field"`
+	Agent   [][]int     `json:"agent"`
+	Special []int       `json:"special"`
+}
+
+// 練習試合開始APIのレスポンス
+type StartResponse struct {
+	Status Status `json:"status"`
+	Start  int64  `json:"start"`
+	GameId int64  `json:"game_id"`
+}
+
+// マッチング参加APIのレスポンス
+type JoinResponse struct {
+	Status  Status  `json:"status"`
+	GameIds []int64 `json:"game_ids"`
+}
+
+// status のみのレスポンス
+type StatusResponse struct {
+	Status Status `json:"status"`
+}
+
+// 移動方向
+// 値は GameLogic.Progress に渡す移動と同じで、0-3: 通常移動, 4-7: 5マス前進 (特殊移動), 8 以上: 指定したマスに移動 (特殊移動)
+type Dir int
+
+// rotation 方向に回転して前進する
+func Normal(rotation int) Dir {
+	return Dir(rotation)
+}
+
+// rotation 方向に回転して 5 マス前進する (特殊移動)
+func Straight(rotation int) Dir {
+	return Dir(rotation + 4)
+}
+
+// マス (i, j, k) に移動する (特殊移動)
+func Teleport(i, j, k int) Dir {
+	return Dir(8 + (i*fieldSize+j)*fieldSize + k)
+}
+
+func (d Dir) IsSpecial() bool {
+	return d >= 4
+}
+
+// 通常移動と 5 マス前進の回転方向
+func (d Dir) Rotation() int {
+	return int(d) % 4
+}
+
+// 瞬間移動先のマス
+func (d Dir) Target() (i, j, k int) {
+	v := int(d) - 8
+	return v / fieldSize / fieldSize, v / fieldSize % fieldSize, v % fieldSize
+}
+
+// 特殊移動を同じ方向の通常移動に置き換える
+// 瞬間移動は前進にする
+func (d Dir) Normal() Dir {
+	if d >= 8 {
+		return Normal(0)
+	}
+	return Normal(d.Rotation())
+}
+
+// 移動APIの {dir} の形式 ("0", "0s", "i-j-k")
+func (d Dir) String() string {
+	switch {
+	case d < 4:
+		return strconv.Itoa(int(d))
+	case d < 8:
+		return strconv.Itoa(d.Rotation()) + "s"
+	default:
+		i, j, k := d.Target()
+		return fmt.Sprintf("%d-%d-%d", i, j, k)
+	}
+}
+
+// 移動APIの {dir} の形式の文字列を Dir に変換する
+func ParseDir(dir string) (Dir, error) {
+	if len(dir) == 1 || len(dir) == 2 && dir[1] == 's' {
+		d, err := strconv.Atoi(dir[:1])
+		if err != nil || d < 0 || d >= 4 {
+			return 0, fmt.Errorf("invalid dir: %s", dir)
+		}
+		if len(dir) == 2 {
+			return Straight(d), nil
+		}
+		return Normal(d), nil
+	}
+	ijk := strings.Split(dir, "-")
+	if len(ijk) != 3 {
+		return 0, fmt.Errorf("invalid dir: %s", dir)
+	}
+	var v [3]int
+	for n, s := range ijk {
+		x, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid dir: %s", dir)
+		}
+		v[n] = x
+	}
+	if v[0] < 0 || v[0] >= 6 || v[1] < 0 || v[1] >= fieldSize || v[2] < 0 || v[2] >= fieldSize {
+		return 0, fmt.Errorf("invalid dir: %s", dir)
+	}
+	return Teleport(v[0], v[1], v[2]), nil
+}
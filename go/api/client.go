@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultServer = "https://gbc2023.tenka1.klab.jp"
+
+// ゲームサーバのAPIが 200 以外のステータスコードを返した場合のエラー
+type HTTPError struct {
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("Api Error status_code:%d", e.StatusCode)
+}
+
+// 全てのクライアントで共有するトランスポート (接続を使い回す)
+var transport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:        16,
+	MaxIdleConnsPerHost: 4,
+	IdleConnTimeout:     90 * time.Second,
+	TLSHandshakeTimeout: time.Second,
+	ForceAttemptHTTP2:   true,
+}
+
+// ゲームサーバのAPIのクライアント
+// リクエストの締め切りは ctx で指定する
+type Client struct {
+	Server string
+	Token  string
+	// err != nil の場合または 5xx エラーの場合に RetryInterval 空けて Retries 回まで再試行する
+	Retries       int
+	RetryInterval time.Duration
+	// リクエストの URL とエラーの出力先 (nil の場合は出力しない)
+	Log *log.Logger
+
+	http    *http.Client
+	mu      sync.Mutex
+	latency map[string]*Histogram
+}
+
+func NewClient(server, token string) *Client {
+	return &Client{
+		Server:        server,
+		Token:         token,
+		Retries:       5,
+		RetryInterval: 100 * time.Millisecond,
+		http:          &http.Client{Transport: transport},
+		latency:       map[string]*Histogram{},
+	}
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Log != nil {
+		c.Log.Printf(format, args...)
+	}
+}
+
+func (c *Client) record(endpoint string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.latency[endpoint]
+	if !ok {
+		h = &Histogram{}
+		c.latency[endpoint] = h
+	}
+	h.Add(d)
+}
+
+// 1 回のリクエストを行う
+// 接続を使い回せるようにレスポンスのボディは最後まで読んで閉じる
+func (c *Client) get(ctx context.Context, endpoint, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.record(endpoint, time.Since(start))
+	return body, resp.StatusCode, nil
+}
+
+// path にリクエストを行い、レスポンスのボディを返す
+// endpoint は所要時間を集計する名前
+func (c *Client) Call(ctx context.Context, endpoint, path string) ([]byte, error) {
+	return c.call(ctx, endpoint, path, c.Retries)
+}
+
+// retries 回までリクエストを行う
+func (c *Client) call(ctx context.Context, endpoint, path string, retries int) ([]byte, error) {
+	url := c.Server + path
+	var lastErr error
+	for i := 0; i < retries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(c.RetryInterval):
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			}
+		}
+		c.logf("%s", url)
+		body, status, err := c.get(ctx, endpoint, url)
+		if err != nil {
+			c.logf("%v", err)
+			lastErr = err
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		if status == http.StatusOK {
+			return body, nil
+		}
+		lastErr = &HTTPError{StatusCode: status}
+		if 500 <= status && status < 600 {
+			c.logf("%d %s", status, http.StatusText(status))
+			continue
+		}
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("retry limit exceeded: %w", lastErr)
+}
+
+func (c *Client) callJSON(ctx context.Context, endpoint, path string, retries int, v interface{}) error {
+	body, err := c.call(ctx, endpoint, path, retries)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// GET /api/move/{token}/{game_id}/{dir0}/{dir5}
+// ターンが進むまでレスポンスは返らない
+func (c *Client) Move(ctx context.Context, gameId int64, dir0, dir5 Dir) (*MoveResponse, error) {
+	var res MoveResponse
+	if err := c.callJSON(ctx, "move", fmt.Sprintf("/api/move/%s/%d/%s/%s", c.Token, gameId, dir0, dir5), c.Retries, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GET /api/start/{token}/{mode}/{delay}
+// 再試行しない (Join と同じ)
+func (c *Client) Start(ctx context.Context, mode, delay int) (*StartResponse, error) {
+	var res StartResponse
+	if err := c.callJSON(ctx, "start", fmt.Sprintf("/api/start/%s/%d/%d", c.Token, mode, delay), 1, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GET /api/join/{token}
+// 1 秒以内に呼び直すと error_time_limit になり、リクエストが届いていた場合は重複して参加するので再試行しない
+func (c *Client) Join(ctx context.Context) (*JoinResponse, error) {
+	var res JoinResponse
+	if err := c.callJSON(ctx, "join", fmt.Sprintf("/api/join/%s", c.Token), 1, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GET /api/data/{token}/{game_id}
+// ビジュアライザが使うゲームのデータ (形式は公開されていないのでそのまま返す)
+func (c *Client) Data(ctx context.Context, gameId int64) (json.RawMessage, error) {
+	body, err := c.Call(ctx, "data", fmt.Sprintf("/api/data/%s/%d", c.Token, gameId))
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(body), nil
+}
+
+// GET /event/{token}
+// ビジュアライザが使うイベントのストリーム (形式は公開されていないのでボディをそのまま返す)
+// 呼び出し側で Close する
+func (c *Client) Events(ctx context.Context) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/event/%s", c.Server, c.Token), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &HTTPError{StatusCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// エンドポイントごとの所要時間の分布 (1 行に 1 エンドポイント)
+func (c *Client) LatencyReport() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	for name := range c.latency {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("latency %s: %s", name, c.latency[name]))
+	}
+	return lines
+}
+
+// 所要時間のヒストグラム (1ms 刻み)
+type Histogram struct {
+	// buckets[i] は i ms 以上 i+1 ms 未満の回数 (最後は HistogramMax 以上)
+	buckets [HistogramMax/time.Millisecond + 1]int
+	count   int
+	sum     time.Duration
+	max     time.Duration
+}
+
+const HistogramMax = 2 * time.Second
+
+func (h *Histogram) Add(d time.Duration) {
+	i := int(d / time.Millisecond)
+	if i >= len(h.buckets) {
+		i = len(h.buckets) - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	h.buckets[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+func (h *Histogram) Count() int {
+	return h.count
+}
+
+// q 分位点 (0 < q <= 1) のバケットの上限
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int(q*float64(h.count) + 0.999999)
+	if rank < 1 {
+		rank = 1
+	}
+	n := 0
+	for i, c := range h.buckets {
+		n += c
+		if n >= rank {
+			if i == len(h.buckets)-1 {
+				return h.max
+			}
+			return time.Duration(i+1) * time.Millisecond
+		}
+	}
+	return h.max
+}
+
+func (h *Histogram) String() string {
+	if h.count == 0 {
+		return "n=0"
+	}
+	return fmt.Sprintf("n=%d avg=%s p50=%s p95=%s p99=%s max=%s", h.count,
+		(h.sum / time.Duration(h.count)).Round(100*time.Microsecond), h.Quantile(0.5), h.Quantile(0.95), h.Quantile(0.99), h.max.Round(100*time.Microsecond))
+}
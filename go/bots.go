@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"tenka/game"
+)
+
+// 毎ターンの移動を決める戦略
+type Strategy interface {
+	// move は移動APIの最新のレスポンス
+	// 返り値は移動APIの {dir0}, {dir5} と同じ形式
+	Decide(move *MoveResponse) (dir0, dir5 string)
+}
+
+// 名前で選べる bot の一覧
+// r は bot が使う乱数
+var strategies = map[string]func(r *rand.Rand) Strategy{
+	"v10":     func(r *rand.Rand) Strategy { return NewVersion10(r) },
+	"random":  func(r *rand.Rand) Strategy { return &RandomWalker{rand: r} },
+	"greedy":  func(r *rand.Rand) Strategy { return &GreedyPainter{rand: r} },
+	"breaker": func(r *rand.Rand) Strategy { return &EnemyBreaker{rand: r} },
+	"sniper":  func(r *rand.Rand) Strategy { return &SpecialSniper{GreedyPainter{rand: r}} },
+}
+
+func NewStrategy(name string, r *rand.Rand) (Strategy, error) {
+	f, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown bot: %s", name)
+	}
+	return f(r), nil
+}
+
+func StrategyNames() []string {
+	var names []string
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// agent から target を満たす最も近いマスに向かう移動方向を返す
+// target はマスの状態から、対象かどうかと距離から差し引く値を返す
+// 同じ距離の方向が複数ある場合はランダムに選び、見つからない場合は -1 を返す
+func nearestDirection(move *MoveResponse, agent int, target func(state []int) (bool, int), r *rand.Rand) int {
+	maps := move.CreateDirectionMap(agent)
+	best := -1
+	bestLength := 0
+	ties := 0
+	for d := 0; d < 4; d++ {
+		found := false
+		length := 0
+		for i := 0; i < 6; i++ {
+			for j := 0; j < game.N; j++ {
+				for k := 0; k < game.N; k++ {
+					l := maps[d][i][j][k]
+					if l <= 0 {
+						continue
+					}
+					ok, bonus := target(move.Field[i][j][k])
+					if ok && (!found || l-bonus < length) {
+						found = true
+						length = l - bonus
+					}
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+		if best == -1 || length < bestLength {
+			best, bestLength, ties = d, length, 1
+		} else if length == bestLength {
+			// 同じ距離の方向から等確率で選ぶ
+			ties++
+			if r.Intn(ties) == 0 {
+				best = d
+			}
+		}
+	}
+	return best
+}
+
+func emptyCell(state []int) (bool, int) {
+	return state[0] == -1, 0
+}
+
+func notFullSelfCell(state []int) (bool, int) {
+	return state[0] != 0 || state[1] != 2, 0
+}
+
+// 敵プレイヤーに塗られたマスは誰にも塗られていないマスより 1 マス近いとみなす
+func enemyOrEmptyCell(state []int) (bool, int) {
+	if state[0] > 0 {
+		return true, 1
+	}
+	return state[0] == -1, 0
+}
+
+// target を満たすマスに向かい、なければ次の候補、それもなければランダムに移動する
+func greedyDirection(move *MoveResponse, agent int, r *rand.Rand, targets ...func(state []int) (bool, int)) string {
+	for _, target := range targets {
+		if d := nearestDirection(move, agent, target, r); d >= 0 {
+			return strconv.Itoa(d)
+		}
+	}
+	return strconv.Itoa(r.Intn(4))
+}
+
+// 毎ターンランダムな方向に移動する bot
+type RandomWalker struct {
+	rand *rand.Rand
+}
+
+func (b *RandomWalker) Decide(move *MoveResponse) (string, string) {
+	return strconv.Itoa(b.rand.Intn(4)), strconv.Itoa(b.rand.Intn(4))
+}
+
+// 最も近い誰にも塗られていないマスに向かう bot
+type GreedyPainter struct {
+	rand *rand.Rand
+}
+
+func (b *GreedyPainter) Decide(move *MoveResponse) (string, string) {
+	return greedyDirection(move, 0, b.rand, emptyCell, notFullSelfCell), greedyDirection(move, 5, b.rand, emptyCell, notFullSelfCell)
+}
+
+// 敵プレイヤーに塗られたマスを優先して塗り返しに向かう bot
+type EnemyBreaker struct {
+	rand *rand.Rand
+}
+
+func (b *EnemyBreaker) Decide(move *MoveResponse) (string, string) {
+	return greedyDirection(move, 0, b.rand, enemyOrEmptyCell, notFullSelfCell), greedyDirection(move, 5, b.rand, enemyOrEmptyCell, notFullSelfCell)
+}
+
+// 特殊移動で取れるマスが SniperMinPoint 以上になったらすぐに特殊移動を使う bot
+// 通常移動は GreedyPainter と同じ
+type SpecialSniper struct {
+	GreedyPainter
+}
+
+const SniperMinPoint = 6
+
+func (b *SpecialSniper) Decide(move *MoveResponse) (string, string) {
+	dir0, dir5 := b.GreedyPainter.Decide(move)
+	if move.Special[0] == 0 && move.Special[5] == 0 {
+		return dir0, dir5
+	}
+	special0, special5 := PlanSpecials(move, move.Target())
+	if special0 != nil && special0.Point() >= SniperMinPoint {
+		dir0 = special0.ApiCall()
+	}
+	if special5 != nil && special5.Point() >= SniperMinPoint {
+		dir5 = special5.ApiCall()
+	}
+	return dir0, dir5
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tenka/league"
+	"tenka/rating"
+	"tenka/sim"
+)
+
+// 決勝リーグに進出する人数
+const FinalistCount = 8
+
+type botList []string
+
+func (l *botList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *botList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// "N*command" の形式の指定を人数とコマンドに分ける
+func parseSpec(spec string) (int, string) {
+	if i := strings.Index(spec, "*"); i > 0 {
+		if n, err := strconv.Atoi(spec[:i]); err == nil {
+			return n, spec[i+1:]
+		}
+	}
+	return 1, spec
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 1 回のマッチングのゲームを全て実行し、結果をリーグに記録する
+func playGames(s *sim.Simulator, l *league.League, games []league.Game, parallel int, seed int64) {
+	scores := make([][]int, len(games))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for n, g := range games {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(n int, g league.Game) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var players [3]sim.Player
+			for p, participant := range g.Players {
+				if participant != nil {
+					players[p] = sim.NewPlayer(participant.Name, seed+int64(n*4+p))
+				}
+			}
+			res, err := s.Play(context.Background(), players, seed+int64(n*4+3))
+			if err != nil {
+				log.Printf("class %d: %v", g.Class, err)
+				return
+			}
+			scores[n] = res.Score
+		}(n, g)
+	}
+	wg.Wait()
+
+	// 並列実行しても結果が変わらないようにゲームの順に記録する
+	for n, g := range games {
+		if scores[n] != nil {
+			l.Record(g, scores[n])
+		}
+	}
+}
+
+// bot ごとのクラスの分布を表示する
+func printClasses(l *league.League, names []string) {
+	for _, name := range names {
+		count := map[int]int{}
+		maxClass := 0
+		for _, p := range l.Ranking {
+			if p.Name == name {
+				count[p.Class]++
+				if p.Class > maxClass {
+					maxClass = p.Class
+				}
+			}
+		}
+		var b strings.Builder
+		for c := 1; c <= maxClass; c++ {
+			if count[c] > 0 {
+				fmt.Fprintf(&b, " class%d=%d", c, count[c])
+			}
+		}
+		log.Printf("  %-30s%s", name, b.String())
+	}
+}
+
+// 予選リーグのマッチングとクラスを再現し、bot の集団でクラスの昇格・降格をシミュレーションするリーグシミュレータ
+// 各参加者は毎回マッチング参加APIを実行したものとし、-absent の確率で参加しない
+//
+//	go run ./cmd/league -matchings 20 -parallel 8 -bot ./tenka -bot "40*random" -bot "10*./tenka-old"
+func main() {
+	var bots botList
+	flag.Var(&bots, "bot", `bot command, "random" or "npc" with an optional "N*" prefix for N participants`)
+	matchings := flag.Int("matchings", 10, "number of matchings")
+	parallel := flag.Int("parallel", 4, "number of games played in parallel")
+	deadline := flag.Duration("deadline", 500*time.Millisecond, "deadline of a turn")
+	logDir := flag.String("log-dir", "", "directory for the output and replay files of bot commands")
+	seed := flag.Int64("seed", 1, "seed of matchmaking and absence")
+	absent := flag.Float64("absent", 0, "probability that a participant does not join a matching")
+	flag.Parse()
+
+	var participants []*league.Participant
+	var names []string
+	for _, spec := range bots {
+		n, name := parseSpec(spec)
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+		for i := 0; i < n; i++ {
+			participants = append(participants, &league.Participant{Id: len(participants), Name: name})
+		}
+	}
+	if len(participants) == 0 {
+		fmt.Fprintln(os.Stderr, "specify participants with -bot")
+		os.Exit(2)
+	}
+	if *logDir != "" {
+		if err := os.MkdirAll(*logDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	s, err := sim.NewSimulator(sim.Options{
+		Deadline: *deadline,
+		LogDir:   *logDir,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Close()
+
+	r := rand.New(rand.NewSource(*seed))
+	l := league.New(r)
+	for m := 1; m <= *matchings+1; m++ {
+		// マッチング参加APIを実行した順はランダムとする
+		var joined []*league.Participant
+		for _, p := range participants {
+			if r.Float64() >= *absent {
+				joined = append(joined, p)
+			}
+		}
+		r.Shuffle(len(joined), func(a, b int) { joined[a], joined[b] = joined[b], joined[a] })
+
+		// 最後のマッチングでは順位を確定するだけでゲームは行わない
+		last := m == *matchings+1
+		games := l.Matching(joined, last)
+		if last {
+			break
+		}
+		start := time.Now()
+		playGames(s, l, games, *parallel, *seed*1000000+int64(m)*10000)
+		log.Printf("matching %d: %d participants, %d games in %s", m, len(l.Ranking), len(games), time.Since(start).Round(time.Millisecond))
+		printClasses(l, names)
+	}
+
+	fmt.Printf("%5s %6s %8s %-30s\n", "rank", "class", "score", "bot")
+	for rank, p := range l.Ranking {
+		score := "N/A"
+		if p.HasScore {
+			score = fmt.Sprintf("%+.2f", p.Score)
+		}
+		fmt.Printf("%5d %6d %8s %-30s\n", rank+1, p.Class, score, p.Name)
+	}
+
+	fmt.Println()
+	fmt.Printf("%-30s %17s %10s %10s\n", "bot", "final rank", "best rank", "finalists")
+	for _, name := range names {
+		var ranks rating.Sample
+		best := 0
+		finalists := 0
+		for rank, p := range l.Ranking {
+			if p.Name != name {
+				continue
+			}
+			ranks.Add(float64(rank + 1))
+			if best == 0 {
+				best = rank + 1
+			}
+			if rank < FinalistCount {
+				finalists++
+			}
+		}
+		if ranks.N == 0 {
+			continue
+		}
+		fmt.Printf("%-30s %7.1f ± %7.1f %10d %10d\n", name, ranks.Mean(), ranks.CI95(), best, finalists)
+	}
+}
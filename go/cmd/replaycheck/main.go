@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"tenka/replay"
+)
+
+// リプレイファイルを GameLogic で再シミュレーションし、サーバのレスポンスとの差異を報告する
+//
+//	go run ./cmd/replaycheck output/10000-YOUR_TOKEN.jsonl
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: replaycheck replay.jsonl...")
+		os.Exit(2)
+	}
+
+	ok, err := check(os.Stdout, flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// 各リプレイファイルの差異を w に出力し、全てのファイルに差異がなければ true を返す
+func check(w io.Writer, paths []string) (bool, error) {
+	ok := true
+	for _, path := range paths {
+		r, err := replay.Load(path)
+		if err != nil {
+			return false, err
+		}
+		divergences, checked := r.Validate()
+		for _, d := range divergences {
+			fmt.Fprintf(w, "%s: %s\n", path, d)
+		}
+		fmt.Fprintf(w, "%s: game %d, %d turns checked, %d divergences\n", path, r.Header.GameId, checked, len(divergences))
+		if len(divergences) > 0 {
+			ok = false
+		}
+	}
+	return ok, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"tenka/game"
+	"tenka/replay"
+	"tenka/server"
+)
+
+// サーバで 1 試合を進めてリプレイファイルを書き、パスを返す
+func writeGame(t *testing.T, dir string) string {
+	t.Helper()
+	srv := server.NewServer(server.Options{
+		TurnDuration:      time.Minute,
+		FirstTurnDeadline: time.Minute,
+		Synchronous:       true,
+		Seed:              1,
+	})
+	m := srv.StartMatchWithSeed([3]string{"a"}, server.ModeRandom, 1)
+	w, err := replay.Create(dir, m.Id, "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	res := m.State(0)
+	for res.Turn < game.TOTAL_TURN {
+		if err := w.Write(&replay.Record{Response: res, Dir0: "0", Dir5: "2"}); err != nil {
+			t.Fatal(err)
+		}
+		if res, err = m.Move(context.Background(), 0, "0", "2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(&replay.Record{Response: res}); err != nil {
+		t.Fatal(err)
+	}
+	return replay.Path(dir, m.Id, "a")
+}
+
+func TestCheck(t *testing.T) {
+	path := writeGame(t, t.TempDir())
+	var out bytes.Buffer
+	ok, err := check(&out, []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !strings.Contains(out.String(), "0 divergences") {
+		t.Errorf("ok = %v, output = %q", ok, out.String())
+	}
+}
+
+// スコアを書き換えたリプレイファイルは差異を報告して false を返す
+func TestCheckTampered(t *testing.T) {
+	path := writeGame(t, t.TempDir())
+	r, err := replay.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Records[100].Response.Score[0]++
+
+	dir := t.TempDir()
+	w, err := replay.Create(dir, r.Header.GameId, "a", r.Header.Seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range r.Records {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	var out bytes.Buffer
+	ok, err := check(&out, []string{path, replay.Path(dir, r.Header.GameId, "a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("tampered replay is not reported")
+	}
+	if !strings.Contains(out.String(), "turn 100: score 0") {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestCheckMissingFile(t *testing.T) {
+	if _, err := check(&bytes.Buffer{}, []string{os.DevNull + "/missing.jsonl"}); err == nil {
+		t.Error("missing file is not an error")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"tenka/game"
+	"tenka/replay"
+)
+
+var (
+	ownerColors = []string{"\033[31m", "\033[32m", "\033[34m"}
+	resetColor  = "\033[0m"
+	// 方向 d の矢印 (j が行、k が列)
+	dirArrows = []string{"v", ">", "^", "<"}
+)
+
+type viewer struct {
+	r     *replay.Replay
+	pos   int
+	color bool
+}
+
+func (v *viewer) colored(owner int, s string) string {
+	if !v.color || owner < 0 {
+		return s
+	}
+	return ownerColors[owner] + s + resetColor
+}
+
+// マス (i, j, k) を 4 文字で表す
+// 塗ったプレイヤー (. は誰にも塗られていない)、塗られた状態 (# は完全、+ は半分)、エージェント (a-f) と方向
+func (v *viewer) cell(g *game.GameLogic, i, j, k int) string {
+	c := g.GetCell(i, j, k)
+	s := " . "
+	if c.Owner >= 0 {
+		state := "+"
+		if c.Val == 2 {
+			state = "#"
+		}
+		s = v.colored(c.Owner, strconv.Itoa(c.Owner)+state) + " "
+	}
+	for idx, a := range g.Agents {
+		if a.I == i && a.J == j && a.K == k {
+			s = s[:len(s)-1] + v.colored(game.Agent2Player(idx), string(rune('a'+idx))+dirArrows[a.D])
+			return s
+		}
+	}
+	return s + " "
+}
+
+func (v *viewer) render() {
+	rec := v.r.Records[v.pos]
+	g := game.NewGameLogic(rec.Response)
+	var b strings.Builder
+	if v.color {
+		b.WriteString("\033[H\033[2J")
+	}
+	fmt.Fprintf(&b, "game %d  seed %d  turn %d/%d  (record %d/%d)\n\n", v.r.Header.GameId, v.r.Header.Seed, g.Turn, game.TOTAL_TURN, v.pos+1, len(v.r.Records))
+	for row := 0; row < 2; row++ {
+		for n := 0; n < 3; n++ {
+			fmt.Fprintf(&b, "%-22s", fmt.Sprintf("face %d", row*3+n))
+		}
+		b.WriteString("\n")
+		for j := 0; j < game.N; j++ {
+			for n := 0; n < 3; n++ {
+				for k := 0; k < game.N; k++ {
+					b.WriteString(v.cell(g, row*3+n, j, k))
+				}
+				b.WriteString("  ")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	for p := 0; p < 3; p++ {
+		fmt.Fprintf(&b, "%s score = %6d  area = %3d\n", v.colored(p, fmt.Sprintf("player %d", p)), g.Score[p], g.Area[p])
+	}
+	b.WriteString("\n")
+	for idx, a := range g.Agents {
+		fmt.Fprintf(&b, "%s (%d,%d,%d) %s  move = %2d  special = %d\n", v.colored(game.Agent2Player(idx), fmt.Sprintf("agent %c", 'a'+idx)), a.I, a.J, a.K, dirArrows[a.D], g.Move[idx], g.Special[idx])
+	}
+	fmt.Fprintf(&b, "\nnext dir0 = %s, dir5 = %s\n", rec.Dir0, rec.Dir5)
+	b.WriteString("[Enter/n] next  [p] prev  [j N] jump to turn N  [play MS] play  [any] pause  [q] quit\n")
+	fmt.Print(b.String())
+}
+
+func (v *viewer) step(n int) bool {
+	next := v.pos + n
+	if next < 0 || next >= len(v.r.Records) {
+		return false
+	}
+	v.pos = next
+	return true
+}
+
+// 指定したターン以降で最初の記録に移動する
+func (v *viewer) jump(turn int) {
+	for i, rec := range v.r.Records {
+		if rec.Response.Turn >= turn {
+			v.pos = i
+			return
+		}
+	}
+	v.pos = len(v.r.Records) - 1
+}
+
+// 記録したゲームをターンごとにターミナルに表示する
+//
+//	go run ./cmd/replayview output/10000-YOUR_TOKEN.jsonl
+func main() {
+	color := flag.Bool("color", true, "use ANSI escape sequences")
+	turn := flag.Int("turn", 0, "turn to show first")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: replayview [-color=false] [-turn N] replay.jsonl")
+		os.Exit(2)
+	}
+	r, err := replay.Load(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(r.Records) == 0 {
+		log.Fatalf("%s: no records", flag.Arg(0))
+	}
+	v := &viewer{r: r, color: *color}
+	v.jump(*turn)
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	pause := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker = nil
+			tick = nil
+		}
+	}
+	v.render()
+	for {
+		select {
+		case <-tick:
+			if !v.step(1) {
+				pause()
+			}
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			playing := ticker != nil
+			pause()
+			fields := strings.Fields(line)
+			cmd := ""
+			if len(fields) > 0 {
+				cmd = fields[0]
+			}
+			switch cmd {
+			case "", "n":
+				if !playing {
+					v.step(1)
+				}
+			case "p":
+				v.step(-1)
+			case "j":
+				if len(fields) == 2 {
+					if t, err := strconv.Atoi(fields[1]); err == nil {
+						v.jump(t)
+					}
+				}
+			case "play":
+				interval := 200 * time.Millisecond
+				if len(fields) == 2 {
+					if ms, err := strconv.Atoi(fields[1]); err == nil && ms > 0 {
+						interval = time.Duration(ms) * time.Millisecond
+					}
+				}
+				ticker = time.NewTicker(interval)
+				tick = ticker.C
+			case "q":
+				return
+			}
+		}
+		v.render()
+	}
+}
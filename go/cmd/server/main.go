@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"tenka/server"
+)
+
+// オフラインで動作するゲームサーバ
+// bot や gorunner の GAME_SERVER に http://localhost:8081 を指定して使用する
+func main() {
+	port := flag.Int("port", 8081, "listen port")
+	turn := flag.Duration("turn", 500*time.Millisecond, "duration of a turn")
+	matchInterval := flag.Duration("match-interval", 150*time.Second, "interval of matching for join API")
+	startDelay := flag.Duration("start-delay", 0, "delay from matching to game start")
+	synchronous := flag.Bool("sync", false, "advance a turn as soon as all players have moved (-turn is the deadline)")
+	seed := flag.Int64("seed", 0, "seed of matching and NPC moves (0 means the current time)")
+	flag.Parse()
+
+	srv := server.NewServer(server.Options{
+		TurnDuration:  *turn,
+		MatchInterval: *matchInterval,
+		StartDelay:    *startDelay,
+		Synchronous:   *synchronous,
+		Seed:          *seed,
+	})
+	go srv.MatchLoop()
+
+	addr := fmt.Sprint(":", *port)
+	log.Printf("listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, srv))
+}
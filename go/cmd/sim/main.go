@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tenka/sim"
+)
+
+type botList []string
+
+func (l *botList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *botList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// 全プレイヤーの移動が揃った時点でターンを進める対戦シミュレータ
+//
+//	go run ./cmd/sim -games 10 -bot "./tenka" -bot random -bot npc
+func main() {
+	var bots botList
+	flag.Var(&bots, "bot", `bot command, "random" or "npc" (up to 3 times)`)
+	games := flag.Int("games", 1, "number of games")
+	parallel := flag.Int("parallel", 1, "number of games played in parallel")
+	deadline := flag.Duration("deadline", 500*time.Millisecond, "deadline of a turn")
+	logDir := flag.String("log-dir", "", "directory for the output and replay files of bot commands")
+	seed := flag.Int64("seed", 1, "seed of bots and NPCs (game g uses seed + g)")
+	flag.Parse()
+
+	if len(bots) == 0 || len(bots) > 3 {
+		fmt.Fprintln(os.Stderr, "specify 1 to 3 bots with -bot")
+		os.Exit(2)
+	}
+	for len(bots) < 3 {
+		bots = append(bots, "npc")
+	}
+	if *logDir != "" {
+		if err := os.MkdirAll(*logDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	s, err := sim.NewSimulator(sim.Options{
+		Deadline: *deadline,
+		LogDir:   *logDir,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Close()
+
+	var mtx sync.Mutex
+	totalScore := make([]int, 3)
+	wins := make([]float64, 3)
+	played := 0
+
+	sem := make(chan struct{}, *parallel)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for g := 0; g < *games; g++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// 試合 g の bot と NPC の乱数は -seed + g から決める
+			r := rand.New(rand.NewSource(*seed + int64(g)))
+			// 座席による有利不利をなくすため試合ごとに bot の席を入れ替える
+			var players [3]sim.Player
+			var seat [3]int
+			for b := 0; b < 3; b++ {
+				p := (b + g) % 3
+				seat[b] = p
+				players[p] = sim.NewPlayer(bots[b], r.Int63())
+			}
+			res, err := s.Play(context.Background(), players, r.Int63())
+			if err != nil {
+				log.Printf("game %d: %v", g, err)
+				return
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			played += 1
+			// 1 位が同点の場合は 1 勝を分け合う
+			best, n := res.Score[0], 0
+			for _, score := range res.Score {
+				if score > best {
+					best, n = score, 1
+				} else if score == best {
+					n++
+				}
+			}
+			for b := 0; b < 3; b++ {
+				totalScore[b] += res.Score[seat[b]]
+				if res.Score[seat[b]] == best {
+					wins[b] += 1 / float64(n)
+				}
+			}
+			log.Printf("game %d: seed = %d, score = %d %d %d", res.GameId, *seed+int64(g), res.Score[seat[0]], res.Score[seat[1]], res.Score[seat[2]])
+		}(g)
+	}
+	wg.Wait()
+
+	fmt.Printf("%d games in %s\n", played, time.Since(start).Round(time.Millisecond))
+	if played == 0 {
+		return
+	}
+	for b := 0; b < 3; b++ {
+		fmt.Printf("%-30s avg score = %8.1f, wins = %.1f\n", bots[b], float64(totalScore[b])/float64(played), wins[b])
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tenka/rating"
+	"tenka/sim"
+)
+
+type botList []string
+
+func (l *botList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *botList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// 1 試合の結果
+type gameResult struct {
+	// 席ごとの bot の番号とスコア
+	lineup [3]int
+	score  []int
+}
+
+// 試合に参加する bot の組み合わせを列挙する
+// bot が 3 つ以上の場合は異なる 3 つの bot の全ての組み合わせ、2 つの場合は片方が 2 席を使う組み合わせ
+func lineups(n int) [][3]int {
+	if n == 2 {
+		return [][3]int{{0, 0, 1}, {0, 1, 1}}
+	}
+	var res [][3]int
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			for c := b + 1; c < n; c++ {
+				res = append(res, [3]int{a, b, c})
+			}
+		}
+	}
+	return res
+}
+
+// bot のコマンド同士で多数の試合を行い、順位点とレーティングを集計するトーナメント
+//
+//	go run ./cmd/tournament -games 30 -bot "./tenka-v9" -bot "./tenka-v10" -bot random
+func main() {
+	var bots botList
+	flag.Var(&bots, "bot", `bot command, "random" or "npc" (at least 2 times)`)
+	games := flag.Int("games", 30, "number of games")
+	parallel := flag.Int("parallel", 1, "number of games played in parallel")
+	deadline := flag.Duration("deadline", 500*time.Millisecond, "deadline of a turn")
+	logDir := flag.String("log-dir", "", "directory for the output and replay files of bot commands")
+	seed := flag.Int64("seed", 1, "seed of bots and NPCs (game g uses seed + g)")
+	eloK := flag.Float64("elo-k", rating.DefaultEloK, "K factor of the Elo rating")
+	flag.Parse()
+
+	if len(bots) < 2 {
+		fmt.Fprintln(os.Stderr, "specify at least 2 bots with -bot")
+		os.Exit(2)
+	}
+	if *logDir != "" {
+		if err := os.MkdirAll(*logDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	s, err := sim.NewSimulator(sim.Options{
+		Deadline: *deadline,
+		LogDir:   *logDir,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Close()
+
+	candidates := lineups(len(bots))
+	results := make([]*gameResult, *games)
+
+	sem := make(chan struct{}, *parallel)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for g := 0; g < *games; g++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// 組み合わせごとに 3 試合ずつ席を入れ替えて行う
+			base := candidates[(g/3)%len(candidates)]
+			var lineup [3]int
+			var players [3]sim.Player
+			r := rand.New(rand.NewSource(*seed + int64(g)))
+			for p := 0; p < 3; p++ {
+				lineup[p] = base[(p+g)%3]
+				players[p] = sim.NewPlayer(bots[lineup[p]], r.Int63())
+			}
+			res, err := s.Play(context.Background(), players, r.Int63())
+			if err != nil {
+				log.Printf("game %d: %v", g, err)
+				return
+			}
+			results[g] = &gameResult{lineup: lineup, score: res.Score}
+			log.Printf("game %d: seed = %d, %s = %d, %s = %d, %s = %d", res.GameId, *seed+int64(g),
+				bots[lineup[0]], res.Score[0], bots[lineup[1]], res.Score[1], bots[lineup[2]], res.Score[2])
+		}(g)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// 並列実行しても結果が変わらないように試合の順に集計する
+	rankPoints := make([]rating.Sample, len(bots))
+	wins := make([]rating.Sample, len(bots))
+	scores := make([]rating.Sample, len(bots))
+	elo := rating.NewElo(len(bots))
+	elo.K = *eloK
+	played := 0
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		played++
+		points := rating.RankPoints(r.score)
+		w := rating.Wins(r.score)
+		for p, b := range r.lineup {
+			rankPoints[b].Add(points[p])
+			wins[b].Add(w[p])
+			scores[b].Add(float64(r.score[p]))
+		}
+		elo.Update(r.lineup[:], r.score)
+	}
+
+	fmt.Printf("%d games in %s\n", played, elapsed.Round(time.Millisecond))
+	if played == 0 {
+		return
+	}
+	order := make([]int, len(bots))
+	for b := range order {
+		order[b] = b
+	}
+	sort.SliceStable(order, func(a, b int) bool { return elo.Ratings[order[a]] > elo.Ratings[order[b]] })
+
+	fmt.Printf("%-30s %7s %6s %17s %17s %10s\n", "bot", "rating", "games", "rank point", "win rate", "avg score")
+	for _, b := range order {
+		fmt.Printf("%-30s %7.1f %6d %+7.3f ± %7.3f %6.1f%% ± %6.1f%% %10.1f\n",
+			bots[b], elo.Ratings[b], rankPoints[b].N,
+			rankPoints[b].Mean(), rankPoints[b].CI95(),
+			100*wins[b].Mean(), 100*wins[b].CI95(),
+			scores[b].Mean())
+	}
+}
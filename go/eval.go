@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"tenka/game"
+)
+
+// 評価関数の特徴量の重み
+// 設定ファイル (環境変数 WEIGHTS で指定する JSON) で上書きできる
+type Weights struct {
+	// CalcPotential のマスまでの距離 length の価値は (distance_horizon - length) * 特徴量の重みの合計
+	DistanceHorizon float64 `json:"distance_horizon"`
+	Empty           float64 `json:"empty"`
+	EnemyHalf       float64 `json:"enemy_half"`
+	EnemyFull       float64 `json:"enemy_full"`
+	Target          float64 `json:"target"`
+	Contested       float64 `json:"contested"`
+	// 最も優先する移動のポテンシャルが最小で、他の移動との差がこれより大きければ次の候補を選ぶ
+	PotentialGap float64 `json:"potential_gap"`
+	// SpecialPrediction.Point の各マスの重み
+	SpecialTargetFull float64 `json:"special_target_full"`
+	SpecialTargetHalf float64 `json:"special_target_half"`
+	SpecialEnemyFull  float64 `json:"special_enemy_full"`
+	SpecialEmpty      float64 `json:"special_empty"`
+	SpecialContested  float64 `json:"special_contested"`
+	// Prediction.LessThan などで ShortTermPredictionValue と比べるしきい値
+	// 2 つの移動の priority がともに length2_priority より大きければ、2 マス先で塗れるマスの数を優先して比べる
+	Length2Priority float64 `json:"length2_priority"`
+	// priority が empty_priority 以下の移動は rotationOrderEmpty の順に選ぶ
+	EmptyPriority float64 `json:"empty_priority"`
+	// 最も優先する移動の priority が potential_priority より大きい場合だけ potential_gap で次の候補を選ぶ
+	PotentialPriority float64 `json:"potential_priority"`
+}
+
+// 重みのデフォルト値 (Version 10 の値)
+var DefaultWeights = Weights{
+	DistanceHorizon:   11,
+	Empty:             10,
+	EnemyHalf:         8,
+	EnemyFull:         5,
+	Target:            4,
+	Contested:         0,
+	PotentialGap:      700,
+	SpecialTargetFull: 4,
+	SpecialTargetHalf: 2,
+	SpecialEnemyFull:  1,
+	SpecialEmpty:      -1,
+	SpecialContested:  -1,
+	Length2Priority:   float64(SelfHalfMayConflict),
+	EmptyPriority:     float64(EmptyMayConflict),
+	PotentialPriority: float64(SelfHalfMayConflict),
+}
+
+var weights = DefaultWeights
+
+// 名前 (JSON のキー) で重みを調整するための一覧
+var weightParams = []struct {
+	name  string
+	field func(w *Weights) *float64
+}{
+	{"distance_horizon", func(w *Weights) *float64 { return &w.DistanceHorizon }},
+	{"empty", func(w *Weights) *float64 { return &w.Empty }},
+	{"enemy_half", func(w *Weights) *float64 { return &w.EnemyHalf }},
+	{"enemy_full", func(w *Weights) *float64 { return &w.EnemyFull }},
+	{"target", func(w *Weights) *float64 { return &w.Target }},
+	{"contested", func(w *Weights) *float64 { return &w.Contested }},
+	{"potential_gap", func(w *Weights) *float64 { return &w.PotentialGap }},
+	{"special_target_full", func(w *Weights) *float64 { return &w.SpecialTargetFull }},
+	{"special_target_half", func(w *Weights) *float64 { return &w.SpecialTargetHalf }},
+	{"special_enemy_full", func(w *Weights) *float64 { return &w.SpecialEnemyFull }},
+	{"special_empty", func(w *Weights) *float64 { return &w.SpecialEmpty }},
+	{"special_contested", func(w *Weights) *float64 { return &w.SpecialContested }},
+	{"length2_priority", func(w *Weights) *float64 { return &w.Length2Priority }},
+	{"empty_priority", func(w *Weights) *float64 { return &w.EmptyPriority }},
+	{"potential_priority", func(w *Weights) *float64 { return &w.PotentialPriority }},
+}
+
+// 名前の重みを返す (存在しない名前の場合は nil)
+func (w *Weights) Param(name string) *float64 {
+	for _, p := range weightParams {
+		if p.name == name {
+			return p.field(w)
+		}
+	}
+	return nil
+}
+
+// 重みの名前の一覧 (ソート済み)
+func WeightNames() []string {
+	var names []string
+	for _, p := range weightParams {
+		names = append(names, p.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (w Weights) String() string {
+	var b strings.Builder
+	for _, name := range WeightNames() {
+		fmt.Fprintf(&b, "%s=%g ", name, *w.Param(name))
+	}
+	return b.String()
+}
+
+// path の JSON の値で DefaultWeights を上書きした重みを返す
+// 存在しない名前が含まれる場合はエラーにする
+func LoadWeights(path string) (Weights, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Weights{}, err
+	}
+	defer f.Close()
+	w := DefaultWeights
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&w); err != nil {
+		return Weights{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+// 重みを JSON で path に書き込む
+func (w Weights) Save(path string) error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ポテンシャルの計算に使うマスの情報
+type cellContext struct {
+	move   *MoveResponse
+	pos    []int
+	state  []int
+	target int
+}
+
+// マスの特徴量
+// 条件を満たすマスについて、重みとマスまでの近さの積をポテンシャルに加える
+type Feature struct {
+	Name   string
+	Weight func(w *Weights) float64
+	Match  func(c *cellContext) bool
+}
+
+var potentialFeatures = []Feature{
+	// 誰にも塗られていないマス
+	{"empty", func(w *Weights) float64 { return w.Empty }, func(c *cellContext) bool { return c.state[1] == 0 }},
+	// 敵プレイヤーに半分塗られたマス
+	{"enemy_half", func(w *Weights) float64 { return w.EnemyHalf }, func(c *cellContext) bool { return c.state[0] > 0 && c.state[1] == 1 }},
+	// 敵プレイヤーに完全に塗られたマス
+	{"enemy_full", func(w *Weights) float64 { return w.EnemyFull }, func(c *cellContext) bool { return c.state[0] > 0 && c.state[1] == 2 }},
+	// 妨害の対象のプレイヤーに塗られたマス
+	{"target", func(w *Weights) float64 { return w.Target }, func(c *cellContext) bool { return c.state[0] == c.target }},
+	// 次のターンに敵エージェントが塗る可能性があるマス
+	{"contested", func(w *Weights) float64 { return w.Contested }, func(c *cellContext) bool {
+		return len(c.move.EnemiesInLength(c.pos, 0)) > 0 || len(c.move.EnemiesInLength(c.pos, 1)) > 0
+	}},
+}
+
+// lengthMap で到達できるマスの価値の合計
+// 重みが 0 の特徴量は計算しない
+func CalcPotential(move *MoveResponse, target int, lengthMap [][][]int) float64 {
+	weights := move.Weights()
+	var features []Feature
+	var featureWeights []float64
+	for _, f := range potentialFeatures {
+		if w := f.Weight(weights); w != 0 {
+			features = append(features, f)
+			featureWeights = append(featureWeights, w)
+		}
+	}
+	horizon := weights.DistanceHorizon
+
+	potential := 0.0
+	c := cellContext{move: move, target: target, pos: []int{0, 0, 0, 0}}
+	for i := 0; i < 6; i++ {
+		for j := 0; j < game.N; j++ {
+			for k := 0; k < game.N; k++ {
+				length := lengthMap[i][j][k]
+				if length <= 0 {
+					continue
+				}
+				c.pos[0], c.pos[1], c.pos[2] = i, j, k
+				c.state = move.Field[i][j][k]
+				w := 0.0
+				for n, f := range features {
+					if f.Match(&c) {
+						w += featureWeights[n]
+					}
+				}
+				potential += (horizon - float64(length)) * w
+			}
+		}
+	}
+	return potential
+}
@@ -0,0 +1,269 @@
+package game
+
+import (
+	"fmt"
+	"math/bits"
+
+	"tenka/api"
+)
+
+// 150 マス分のビット集合 (ビット FieldIdx(i, j, k) がマス (i, j, k))
+type Bits [3]uint64
+
+func (b *Bits) Has(fi int) bool {
+	return b[fi>>6]&(1<<(fi&63)) != 0
+}
+
+func (b *Bits) Set(fi int) {
+	b[fi>>6] |= 1 << (fi & 63)
+}
+
+func (b *Bits) Clear(fi int) {
+	b[fi>>6] &^= 1 << (fi & 63)
+}
+
+func (b *Bits) Count() int {
+	return bits.OnesCount64(b[0]) + bits.OnesCount64(b[1]) + bits.OnesCount64(b[2])
+}
+
+// forward[fi][d] はマス fi から方向 d に 1 マス前進した先のマスと方向
+// geometry パッケージも Forward でこの表を使う
+var forward [6 * N * N][4][2]int
+
+func init() {
+	for i := 0; i < 6; i++ {
+		for j := 0; j < N; j++ {
+			for k := 0; k < N; k++ {
+				for d := 0; d < 4; d++ {
+					pos := []int{i, j, k, d}
+					MoveForward(pos)
+					forward[FieldIdx(i, j, k)][d] = [2]int{FieldIdx(pos[0], pos[1], pos[2]), pos[3]}
+				}
+			}
+		}
+	}
+}
+
+// マス fi (FieldIdx) で方向 d を向いた状態から 1 マス前進した先のマスと方向 (MoveForward と同じ)
+func Forward(fi, d int) (int, int) {
+	n := forward[fi][d]
+	return n[0], n[1]
+}
+
+// GameLogic と同じルールで進行する盤面
+// 全てのフィールドが値なので、代入で複製でき、Progress はメモリを確保しない
+type Board struct {
+	// Owned[p] はプレイヤー p が塗ったマス、Full[p] はそのうち完全に塗られたマス
+	Owned [3]Bits
+	Full  [3]Bits
+	// エージェントのマス (FieldIdx) と方向
+	Agents  [6][2]int
+	Turn    int
+	Move    [6]int
+	Score   [3]int
+	Area    [3]int
+	Special [6]int
+}
+
+// マスの所有者 (-1: 誰にも塗られていない) と状態 (0: 塗られていない, 1: 半分, 2: 完全)
+func (b *Board) Cell(fi int) (owner, val int) {
+	for p := 0; p < 3; p++ {
+		if b.Owned[p].Has(fi) {
+			if b.Full[p].Has(fi) {
+				return p, 2
+			}
+			return p, 1
+		}
+	}
+	return -1, 0
+}
+
+// エージェントの位置 ([i, j, k, d])
+func (b *Board) Agent(idx int) []int {
+	fi := b.Agents[idx][0]
+	return []int{fi / N / N, fi / N % N, fi % N, b.Agents[idx][1]}
+}
+
+// moves に従って 1 ターン進行する
+// GameLogic.Progress と同じく、無効な移動を含む場合はエラーを返して進行しない
+func (b *Board) Progress(memberId int, moves [6]int) error {
+	if memberId < 0 || memberId >= 6 {
+		return fmt.Errorf("invalid memberId: %d", memberId)
+	}
+	for idx := 0; idx < 6; idx++ {
+		v := moves[Func1(memberId, idx)]
+		if v < -1 || v >= MaxMove {
+			return fmt.Errorf("turn %d: invalid move of agent %d: %d", b.Turn, idx, v)
+		}
+		if v >= 4 && b.Special[idx] <= 0 {
+			return fmt.Errorf("turn %d: special move of agent %d is already used: %d", b.Turn, idx, v)
+		}
+	}
+
+	// エージェントの移動処理
+	for idx := 0; idx < 6; idx++ {
+		b.Move[idx] = moves[Func1(memberId, idx)]
+		if b.Move[idx] == -1 || b.Move[idx] >= 4 {
+			continue
+		}
+		a := &b.Agents[idx]
+		*a = forward[a[0]][(a[1]+b.Move[idx])%4]
+	}
+
+	// フィールドの更新処理 (通常移動)
+	for idx := 0; idx < 6; idx++ {
+		if b.Move[idx] == -1 || b.Move[idx] >= 4 {
+			continue
+		}
+		fi := b.Agents[idx][0]
+		var counter byte
+		for other := 0; other < 6; other++ {
+			if b.Move[other] != -1 && b.Move[other] < 4 && b.Agents[other][0] == fi {
+				counter |= 1 << other
+			}
+		}
+		ownerId := Agent2Player(idx)
+		if counter == 1<<idx || counter == (1<<idx)|(1<<ownerId) || b.Owned[ownerId].Has(fi) {
+			b.paint(ownerId, fi)
+		}
+	}
+
+	// フィールドの更新処理 (特殊移動)
+	var targets [3]Bits
+	special := false
+	for idx := 0; idx < 6; idx++ {
+		if b.Move[idx] <= 3 {
+			continue
+		}
+		special = true
+		b.Special[idx] -= 1
+		ownerId := Agent2Player(idx)
+		a := &b.Agents[idx]
+		if b.Move[idx] <= 7 {
+			// 5 マス前進
+			a[1] = (a[1] + b.Move[idx]) % 4
+			for p := 0; p < 5; p++ {
+				*a = forward[a[0]][a[1]]
+				targets[ownerId].Set(a[0])
+			}
+		} else {
+			// 指定したマスに移動
+			m := b.Move[idx] - 8
+			fi := FieldIdx(Func1(ownerId, m/(N*N)), m/N%N, m%N)
+			targets[ownerId].Set(fi)
+			for d := 0; d < 4; d++ {
+				targets[ownerId].Set(forward[fi][d][0])
+			}
+			*a = [2]int{fi, 0}
+		}
+	}
+	if special {
+		for p := 0; p < 3; p++ {
+			// 他のプレイヤーと重ならないマスだけを完全に塗る
+			for w := 0; w < len(targets[p]); w++ {
+				only := targets[p][w] &^ targets[(p+1)%3][w] &^ targets[(p+2)%3][w]
+				for q := 0; q < 3; q++ {
+					b.Owned[q][w] &^= only
+					b.Full[q][w] &^= only
+				}
+				b.Owned[p][w] |= only
+				b.Full[p][w] |= only
+			}
+		}
+	}
+
+	for p := 0; p < 3; p++ {
+		b.Area[p] = b.Owned[p].Count()
+	}
+
+	// Score 更新
+	if b.Turn >= TOTAL_TURN/2 {
+		for p := 0; p < 3; p++ {
+			b.Score[p] += b.Area[p]
+		}
+	}
+
+	b.Turn += 1
+	return nil
+}
+
+// マス fi を ownerId が塗る (通常移動)
+func (b *Board) paint(ownerId, fi int) {
+	for p := 0; p < 3; p++ {
+		if !b.Owned[p].Has(fi) {
+			continue
+		}
+		if p == ownerId {
+			// ownerId で塗られている場合は完全に塗られた状態に上書きする
+			b.Full[p].Set(fi)
+		} else if b.Full[p].Has(fi) {
+			// ownerId 以外で完全に塗られた状態の場合は半分塗られた状態にする
+			b.Full[p].Clear(fi)
+		} else {
+			// ownerId 以外で半分塗られた状態の場合は誰にも塗られていない状態にする
+			b.Owned[p].Clear(fi)
+		}
+		return
+	}
+	// 誰にも塗られていない場合は ownerId で塗る
+	b.Owned[ownerId].Set(fi)
+	b.Full[ownerId].Set(fi)
+}
+
+// 移動APIのレスポンスから盤面を作る
+func NewBoard(move *MoveResponse) *Board {
+	b := &Board{Turn: move.Turn}
+	for i := 0; i < 6; i++ {
+		for j := 0; j < N; j++ {
+			for k := 0; k < N; k++ {
+				owner := move.Field[i][j][k][0]
+				if owner < 0 {
+					continue
+				}
+				fi := FieldIdx(i, j, k)
+				b.Owned[owner].Set(fi)
+				if move.Field[i][j][k][1] == 2 {
+					b.Full[owner].Set(fi)
+				}
+			}
+		}
+	}
+	for idx := 0; idx < 6; idx++ {
+		a := move.Agent[idx]
+		b.Agents[idx] = [2]int{FieldIdx(a[0], a[1], a[2]), a[3]}
+	}
+	copy(b.Move[:], move.Move)
+	copy(b.Score[:], move.Score)
+	copy(b.Special[:], move.Special)
+	for p := 0; p < 3; p++ {
+		b.Area[p] = b.Owned[p].Count()
+	}
+	return b
+}
+
+// 移動APIのレスポンスと同じ形式に変換する
+func (b *Board) Response() *MoveResponse {
+	res := &MoveResponse{
+		Status:  api.StatusOk,
+		Turn:    b.Turn,
+		Move:    append([]int{}, b.Move[:]...),
+		Score:   append([]int{}, b.Score[:]...),
+		Field:   make([][][][]int, 6),
+		Agent:   make([][]int, 6),
+		Special: append([]int{}, b.Special[:]...),
+	}
+	for i := 0; i < 6; i++ {
+		res.Field[i] = make([][][]int, N)
+		for j := 0; j < N; j++ {
+			res.Field[i][j] = make([][]int, N)
+			for k := 0; k < N; k++ {
+				owner, val := b.Cell(FieldIdx(i, j, k))
+				res.Field[i][j][k] = []int{owner, val}
+			}
+		}
+	}
+	for idx := 0; idx < 6; idx++ {
+		res.Agent[idx] = b.Agent(idx)
+	}
+	return res
+}
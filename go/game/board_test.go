@@ -0,0 +1,143 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func checkBoard(t *testing.T, b *Board, g *GameLogic) {
+	t.Helper()
+	for fi, c := range g.Field {
+		if owner, val := b.Cell(fi); owner != c.Owner || val != c.Val {
+			t.Fatalf("turn %d field %d: Board = (%d, %d), GameLogic = %+v", g.Turn, fi, owner, val, *c)
+		}
+	}
+	for idx, a := range g.Agents {
+		got := b.Agent(idx)
+		if got[0] != a.I || got[1] != a.J || got[2] != a.K || got[3] != a.D {
+			t.Fatalf("turn %d agent %d: Board = %v, GameLogic = %+v", g.Turn, idx, got, *a)
+		}
+		if b.Special[idx] != g.Special[idx] || b.Move[idx] != g.Move[idx] {
+			t.Fatalf("turn %d agent %d: Board special %d move %d, GameLogic special %d move %d", g.Turn, idx, b.Special[idx], b.Move[idx], g.Special[idx], g.Move[idx])
+		}
+	}
+	for p := 0; p < 3; p++ {
+		if b.Score[p] != g.Score[p] || b.Area[p] != g.Area[p] {
+			t.Fatalf("turn %d: Board score %v area %v, GameLogic score %v area %v", g.Turn, b.Score, b.Area, g.Score, g.Area)
+		}
+	}
+	if b.Turn != g.Turn {
+		t.Fatalf("Board turn %d, GameLogic turn %d", b.Turn, g.Turn)
+	}
+}
+
+// ランダムな移動 (特殊移動と無効な移動を含む)
+func randomMoves(r *rand.Rand) [6]int {
+	var moves [6]int
+	for idx := range moves {
+		moves[idx] = r.Intn(5) - 1
+		switch r.Intn(60) {
+		case 0:
+			moves[idx] = 4 + r.Intn(4)
+		case 1:
+			moves[idx] = 8 + r.Intn(6*N*N)
+		case 2:
+			moves[idx] = MaxMove
+		}
+	}
+	return moves
+}
+
+// Board は GameLogic と同じように進行する
+func TestBoardMatchesGameLogic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for game := 0; game < 20; game++ {
+		g := NewInitialGameLogic()
+		b := NewBoard(g.Response())
+		checkBoard(t, b, g)
+		for g.Turn < TOTAL_TURN {
+			memberId := r.Intn(6)
+			moves := randomMoves(r)
+			errG := g.Progress(memberId, moves[:])
+			errB := b.Progress(memberId, moves)
+			if (errG == nil) != (errB == nil) {
+				t.Fatalf("turn %d %v: GameLogic error = %v, Board error = %v", g.Turn, moves, errG, errB)
+			}
+			checkBoard(t, b, g)
+		}
+	}
+}
+
+func TestBoardResponse(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	b := NewBoard(NewInitialGameLogic().Response())
+	for b.Turn < TOTAL_TURN/2+10 {
+		b.Progress(0, randomMoves(r))
+	}
+	if got := NewBoard(b.Response()); *got != *b {
+		t.Errorf("NewBoard(b.Response()) = %+v, want %+v", *got, *b)
+	}
+	checkBoard(t, b, NewGameLogic(b.Response()))
+}
+
+func TestBoardProgressAllocs(t *testing.T) {
+	b := NewBoard(NewInitialGameLogic().Response())
+	moves := [6]int{0, 1, 4, 8 + FieldIdx(3, 1, 1), 2, 3}
+	allocs := testing.AllocsPerRun(100, func() {
+		next := *b
+		next.Progress(0, moves)
+	})
+	if allocs != 0 {
+		t.Errorf("Board.Progress allocates %v times", allocs)
+	}
+}
+
+func benchmarkMoves() [][6]int {
+	r := rand.New(rand.NewSource(3))
+	moves := make([][6]int, 64)
+	for n := range moves {
+		for idx := range moves[n] {
+			moves[n][idx] = r.Intn(4)
+		}
+	}
+	return moves
+}
+
+// 探索と同じく盤面を複製して 1 ターン進める
+func BenchmarkGameLogicProgress(b *testing.B) {
+	g := NewInitialGameLogic()
+	moves := benchmarkMoves()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		next := g.Clone()
+		next.Progress(0, moves[n%len(moves)][:])
+	}
+}
+
+func BenchmarkBoardProgress(b *testing.B) {
+	board := NewBoard(NewInitialGameLogic().Response())
+	moves := benchmarkMoves()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		next := *board
+		next.Progress(0, moves[n%len(moves)])
+	}
+}
+
+func BenchmarkNewGameLogic(b *testing.B) {
+	res := NewInitialGameLogic().Response()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		NewGameLogic(res)
+	}
+}
+
+func BenchmarkNewBoard(b *testing.B) {
+	res := NewInitialGameLogic().Response()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		NewBoard(res)
+	}
+}
@@ -0,0 +1,24 @@
+// game パッケージはゲームのルール (盤面、移動、塗り、スコア) を実装する
+package game
+
+import "tenka/api"
+
+// 1 面の大きさ
+const N = 5
+
+// 1 ゲームのターン数
+const TOTAL_TURN = 294
+
+var Dj = []int{+1, 0, -1, 0}
+var Dk = []int{0, +1, 0, -1}
+
+// 移動APIのレスポンス用の構造体
+type MoveResponse = api.MoveResponse
+
+// エージェントの番号とプレイヤーの番号の対応
+var agentMap = []int{0, 1, 2, 2, 1, 0}
+
+// エージェントの番号からプレイヤーの番号を返す
+func Agent2Player(agent int) int {
+	return agentMap[agent]
+}
@@ -0,0 +1,399 @@
+package game
+
+import (
+	"fmt"
+
+	"tenka/api"
+)
+
+// (i, j, k) を Field の添え字にする
+func FieldIdx(i, j, k int) int {
+	return (i*N+j)*N + k
+}
+
+// Move 用
+func Func1(memberId, pos int) int {
+	i0 := memberId / 3
+	i1 := memberId % 3
+	j0 := pos / 3
+	j1 := pos % 3
+	return ((j0+1)*i1+j1)%3 + (i0+j0)%2*3
+}
+
+type Agent struct {
+	I int
+	J int
+	K int
+	D int
+}
+
+type Cell struct {
+	Owner int
+	Val   int
+}
+
+type GameLogic struct {
+	Field   []*Cell
+	Agents  []*Agent
+	Turn    int
+	Move    []int
+	Score   []int
+	Area    []int
+	Special []int
+}
+
+func (g *GameLogic) GetCell(i, j, k int) *Cell {
+	return g.Field[FieldIdx(i, j, k)]
+}
+
+// 移動の値の上限 (-1: 移動しない, 0-3: 通常移動, 4-7: 5 マス前進, 8 以上: 指定したマスに移動)
+const MaxMove = 8 + 6*N*N
+
+// moveList に従ってゲームを進行する
+// moveList の長さが 6 の倍数でない場合や、無効な移動 (範囲外の値、残り回数が 0 のエージェントの特殊移動) を含む場合はエラーを返す
+// 複数ターン分の moveList の場合は、無効な移動を含むターンの手前まで進行する
+func (g *GameLogic) Progress(memberId int, moveList []int) error {
+	if len(moveList)%6 != 0 {
+		return fmt.Errorf("invalid moveList length: %d", len(moveList))
+	}
+	if memberId < 0 || memberId >= 6 {
+		return fmt.Errorf("invalid memberId: %d", memberId)
+	}
+	counter := make([]byte, 6*N*N)
+	fis := make([]int, 6)
+	for i := 0; i < len(moveList); i += 6 {
+		if err := g.checkMoves(memberId, moveList[i:i+6]); err != nil {
+			return fmt.Errorf("turn %d: %w", g.Turn, err)
+		}
+		// エージェントの移動処理
+		for idx := 0; idx < 6; idx++ {
+			g.Move[idx] = moveList[i+Func1(memberId, idx)]
+			if g.Move[idx] == -1 || g.Move[idx] >= 4 {
+				continue
+			}
+			g.RotateAgent(idx, g.Move[idx])
+			g.MoveForward(idx)
+			ii := g.Agents[idx].I
+			jj := g.Agents[idx].J
+			kk := g.Agents[idx].K
+			fis[idx] = FieldIdx(ii, jj, kk)
+			counter[fis[idx]] |= 1 << idx
+		}
+
+		// フィールドの更新処理 (通常移動)
+		for idx := 0; idx < 6; idx++ {
+			if g.Move[idx] == -1 || g.Move[idx] >= 4 {
+				continue
+			}
+			var ownerId int
+			if idx < 3 {
+				ownerId = idx
+			} else {
+				ownerId = 5 - idx
+			}
+			if g.CheckCounter(counter[fis[idx]], ownerId, idx) || g.Field[fis[idx]].Owner == ownerId {
+				g.Paint(ownerId, fis[idx])
+			}
+		}
+
+		for idx := 0; idx < 6; idx++ {
+			if g.Move[idx] == -1 || g.Move[idx] >= 4 {
+				continue
+			}
+			counter[fis[idx]] = 0
+		}
+
+		// フィールドの更新処理 (特殊移動)
+		specialFis := make(map[int]bool)
+		for idx := 0; idx < 6; idx++ {
+			if g.Move[idx] <= 3 {
+				continue
+			}
+			g.Special[idx] -= 1
+			var ownerId int
+			if idx < 3 {
+				ownerId = idx
+			} else {
+				ownerId = 5 - idx
+			}
+			if g.Move[idx] <= 7 {
+				// 5 マス前進
+				g.RotateAgent(idx, g.Move[idx])
+				for p := 0; p < 5; p++ {
+					g.MoveForward(idx)
+					ii := g.Agents[idx].I
+					jj := g.Agents[idx].J
+					kk := g.Agents[idx].K
+					fi := FieldIdx(ii, jj, kk)
+					specialFis[fi] = true
+					counter[fi] |= 1 << ownerId
+				}
+			} else {
+				// 指定したマスに移動
+				m := g.Move[idx] - 8
+				mi := Func1(ownerId, m/25)
+				mj := m / 5 % 5
+				mk := m % 5
+				{
+					fi := FieldIdx(mi, mj, mk)
+					specialFis[fi] = true
+					counter[fi] |= 1 << ownerId
+				}
+				for d := 0; d < 4; d++ {
+					g.Agents[idx].I = mi
+					g.Agents[idx].J = mj
+					g.Agents[idx].K = mk
+					g.Agents[idx].D = d
+					g.MoveForward(idx)
+					ii := g.Agents[idx].I
+					jj := g.Agents[idx].J
+					kk := g.Agents[idx].K
+					fi := FieldIdx(ii, jj, kk)
+					specialFis[fi] = true
+					counter[fi] |= 1 << ownerId
+				}
+				g.Agents[idx].I = mi
+				g.Agents[idx].J = mj
+				g.Agents[idx].K = mk
+				g.Agents[idx].D = 0
+			}
+		}
+
+		for fi := range specialFis {
+			switch counter[fi] {
+			case 1:
+				g.ForcePaint(0, fi)
+				break
+			case 2:
+				g.ForcePaint(1, fi)
+				break
+			case 4:
+				g.ForcePaint(2, fi)
+				break
+			}
+			counter[fi] = 0
+		}
+
+		// Score 更新
+		if g.Turn >= TOTAL_TURN/2 {
+			g.AddScore()
+		}
+
+		g.Turn += 1
+	}
+	return nil
+}
+
+// 1 ターン分の移動が有効かを判定する
+func (g *GameLogic) checkMoves(memberId int, moves []int) error {
+	for idx := 0; idx < 6; idx++ {
+		v := moves[Func1(memberId, idx)]
+		if v < -1 || v >= MaxMove {
+			return fmt.Errorf("invalid move of agent %d: %d", idx, v)
+		}
+		if v >= 4 && g.Special[idx] <= 0 {
+			return fmt.Errorf("special move of agent %d is already used: %d", idx, v)
+		}
+	}
+	return nil
+}
+
+// ownerId のみが塗ろうとしているかを判定
+func (g *GameLogic) CheckCounter(counter byte, ownerId, idx int) bool {
+	return (counter == 1<<idx) || (counter == ((1 << idx) | (1 << ownerId)))
+}
+
+// Score 更新
+func (g *GameLogic) AddScore() {
+	for i := 0; i < 3; i++ {
+		g.Score[i] += g.Area[i]
+	}
+}
+
+// FieldIdx が fi のマスを ownerId が塗る (通常移動)
+func (g *GameLogic) Paint(ownerId, fi int) {
+	if g.Field[fi].Owner == -1 {
+		// 誰にも塗られていない場合は ownerId で塗る
+		g.Area[ownerId] += 1
+		g.Field[fi].Owner = ownerId
+		g.Field[fi].Val = 2
+	} else if g.Field[fi].Owner == ownerId {
+		// ownerId で塗られている場合は完全に塗られた状態に上書きする
+		g.Field[fi].Val = 2
+	} else if g.Field[fi].Val == 1 {
+		// ownerId 以外で半分塗られた状態の場合は誰にも塗られていない状態にする
+		g.Area[g.Field[fi].Owner] -= 1
+		g.Field[fi].Owner = -1
+		g.Field[fi].Val = 0
+	} else {
+		// ownerId 以外で完全に塗られた状態の場合は半分塗られた状態にする
+		g.Field[fi].Val -= 1
+	}
+}
+
+// FieldIdx が fi のマスを ownerId が塗る (特殊移動)
+func (g *GameLogic) ForcePaint(ownerId, fi int) {
+	if g.Field[fi].Owner != ownerId {
+		g.Area[ownerId] += 1
+		if g.Field[fi].Owner != -1 {
+			g.Area[g.Field[fi].Owner] -= 1
+		}
+	}
+	g.Field[fi].Owner = ownerId
+	g.Field[fi].Val = 2
+}
+
+// idx のエージェントを v 方向に回転させる
+func (g *GameLogic) RotateAgent(idx, v int) {
+	g.Agents[idx].D += v
+	g.Agents[idx].D %= 4
+}
+
+// idx のエージェントを前進させる
+func (g *GameLogic) MoveForward(idx int) {
+	i := g.Agents[idx].I
+	j := g.Agents[idx].J
+	k := g.Agents[idx].K
+	d := g.Agents[idx].D
+	jj := j + Dj[d]
+	kk := k + Dk[d]
+	if jj >= N {
+		g.Agents[idx].I = i/3*3 + (i%3+1)%3 // [1, 2, 0, 4, 5, 3][i];
+		g.Agents[idx].J = k
+		g.Agents[idx].K = N - 1
+		g.Agents[idx].D = 3
+	} else if jj < 0 {
+		g.Agents[idx].I = (1-i/3)*3 + (4-i%3)%3 // [4, 3, 5, 1, 0, 2][i];
+		g.Agents[idx].J = 0
+		g.Agents[idx].K = N - 1 - k
+		g.Agents[idx].D = 0
+	} else if kk >= N {
+		g.Agents[idx].I = i/3*3 + (i%3+2)%3 // [2, 0, 1, 5, 3, 4][i];
+		g.Agents[idx].J = N - 1
+		g.Agents[idx].K = j
+		g.Agents[idx].D = 2
+	} else if kk < 0 {
+		g.Agents[idx].I = (1-i/3)*3 + (3-i%3)%3 // [3, 5, 4, 0, 2, 1][i];
+		g.Agents[idx].J = N - 1 - j
+		g.Agents[idx].K = 0
+		g.Agents[idx].D = 1
+	} else {
+		g.Agents[idx].J = jj
+		g.Agents[idx].K = kk
+	}
+}
+
+// 盤面を複製する
+func (g *GameLogic) Clone() *GameLogic {
+	field := make([]*Cell, len(g.Field))
+	cells := make([]Cell, len(g.Field))
+	for i, c := range g.Field {
+		cells[i] = *c
+		field[i] = &cells[i]
+	}
+	agents := make([]*Agent, len(g.Agents))
+	for i, a := range g.Agents {
+		agent := *a
+		agents[i] = &agent
+	}
+	return &GameLogic{
+		Field:   field,
+		Agents:  agents,
+		Turn:    g.Turn,
+		Move:    append([]int{}, g.Move...),
+		Score:   append([]int{}, g.Score...),
+		Area:    append([]int{}, g.Area...),
+		Special: append([]int{}, g.Special...),
+	}
+}
+
+// 移動APIのレスポンスから盤面を作る
+func NewGameLogic(move *MoveResponse) *GameLogic {
+	field := make([]*Cell, 6*N*N)
+	agents := make([]*Agent, 6)
+	area := make([]int, 3)
+	for i := 0; i < 6; i++ {
+		for j := 0; j < N; j++ {
+			for k := 0; k < N; k++ {
+				owner := move.Field[i][j][k][0]
+				field[FieldIdx(i, j, k)] = &Cell{
+					Owner: owner,
+					Val:   move.Field[i][j][k][1],
+				}
+				if owner >= 0 {
+					area[owner] += 1
+				}
+			}
+		}
+	}
+	for i := 0; i < 6; i++ {
+		agents[i] = &Agent{
+			I: move.Agent[i][0],
+			J: move.Agent[i][1],
+			K: move.Agent[i][2],
+			D: move.Agent[i][3],
+		}
+	}
+	return &GameLogic{
+		Field:   field,
+		Agents:  agents,
+		Turn:    move.Turn,
+		Move:    append([]int{}, move.Move...),
+		Score:   append([]int{}, move.Score...),
+		Area:    area,
+		Special: append([]int{}, move.Special...),
+	}
+}
+
+// 移動APIのレスポンスと同じ形式に変換する
+func (g *GameLogic) Response() *MoveResponse {
+	res := &MoveResponse{
+		Status:  api.StatusOk,
+		Turn:    g.Turn,
+		Move:    append([]int{}, g.Move...),
+		Score:   append([]int{}, g.Score...),
+		Field:   make([][][][]int, 6),
+		Agent:   make([][]int, 6),
+		Special: append([]int{}, g.Special...),
+	}
+	for i := 0; i < 6; i++ {
+		res.Field[i] = make([][][]int, N)
+		for j := 0; j < N; j++ {
+			res.Field[i][j] = make([][]int, N)
+			for k := 0; k < N; k++ {
+				c := g.GetCell(i, j, k)
+				res.Field[i][j][k] = []int{c.Owner, c.Val}
+			}
+		}
+	}
+	for idx, a := range g.Agents {
+		res.Agent[idx] = []int{a.I, a.J, a.K, a.D}
+	}
+	return res
+}
+
+// ゲーム開始時の盤面を作る
+// 各エージェントは (i, 2, 2) に方向 0 で配置され、そのマスは完全に塗られた状態になる
+func NewInitialGameLogic() *GameLogic {
+	field := make([]*Cell, 6*N*N)
+	for i := range field {
+		field[i] = &Cell{Owner: -1, Val: 0}
+	}
+	agents := make([]*Agent, 6)
+	area := make([]int, 3)
+	for i := 0; i < 6; i++ {
+		agents[i] = &Agent{I: i, J: 2, K: 2, D: 0}
+		owner := Agent2Player(i)
+		field[FieldIdx(i, 2, 2)] = &Cell{Owner: owner, Val: 2}
+		area[owner] += 1
+	}
+	return &GameLogic{
+		Field:   field,
+		Agents:  agents,
+		Turn:    0,
+		Move:    []int{-1, -1, -1, -1, -1, -1},
+		Score:   []int{0, 0, 0},
+		Area:    area,
+		Special: []int{1, 1, 1, 1, 1, 1},
+	}
+}
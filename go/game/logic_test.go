@@ -0,0 +1,521 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 誰にも塗られていない盤面で、エージェントを (i, 2, 2) 方向 0 に置いた GameLogic を作る
+func newEmptyLogic() *GameLogic {
+	g := NewInitialGameLogic()
+	for _, c := range g.Field {
+		c.Owner = -1
+		c.Val = 0
+	}
+	g.Area = []int{0, 0, 0}
+	return g
+}
+
+// マスの状態を設定し、Area を合わせる
+func setCell(g *GameLogic, i, j, k, owner, val int) {
+	c := g.GetCell(i, j, k)
+	if c.Owner >= 0 {
+		g.Area[c.Owner]--
+	}
+	c.Owner = owner
+	c.Val = val
+	if owner >= 0 {
+		g.Area[owner]++
+	}
+}
+
+func setAgent(g *GameLogic, idx, i, j, k, d int) {
+	*g.Agents[idx] = Agent{I: i, J: j, K: k, D: d}
+}
+
+// 移動しないエージェントは -1
+func moves(m map[int]int) []int {
+	list := []int{-1, -1, -1, -1, -1, -1}
+	for idx, v := range m {
+		list[idx] = v
+	}
+	return list
+}
+
+// 所有者が決まっているマスの数を数える
+func countArea(g *GameLogic) []int {
+	area := []int{0, 0, 0}
+	for _, c := range g.Field {
+		if c.Owner >= 0 {
+			area[c.Owner]++
+		}
+	}
+	return area
+}
+
+func checkArea(t *testing.T, g *GameLogic) {
+	t.Helper()
+	want := countArea(g)
+	for p := 0; p < 3; p++ {
+		if g.Area[p] != want[p] {
+			t.Errorf("Area = %v, want %v", g.Area, want)
+			return
+		}
+	}
+}
+
+func TestInitialGameLogic(t *testing.T) {
+	g := NewInitialGameLogic()
+	// 初期位置 i=1 と i=4、i=2 と i=3 のエージェントのプレイヤーは同じ
+	wantOwner := []int{0, 1, 2, 2, 1, 0}
+	for i := 0; i < 6; i++ {
+		a := g.Agents[i]
+		if a.I != i || a.J != 2 || a.K != 2 || a.D != 0 {
+			t.Errorf("agent %d = %+v, want (%d, 2, 2) direction 0", i, *a, i)
+		}
+		c := g.GetCell(i, 2, 2)
+		if c.Owner != wantOwner[i] || c.Val != 2 {
+			t.Errorf("cell (%d, 2, 2) = %+v, want owner %d val 2", i, *c, wantOwner[i])
+		}
+		if Agent2Player(i) != wantOwner[i] {
+			t.Errorf("Agent2Player(%d) = %d, want %d", i, Agent2Player(i), wantOwner[i])
+		}
+	}
+	for p := 0; p < 3; p++ {
+		if g.Area[p] != 2 {
+			t.Errorf("Area[%d] = %d, want 2", p, g.Area[p])
+		}
+	}
+}
+
+// 通常移動によるマスの更新処理 (problem.md)
+func TestPaint(t *testing.T) {
+	tests := []struct {
+		name      string
+		owner     int
+		val       int
+		wantOwner int
+		wantVal   int
+	}{
+		{"取得", -1, 0, 0, 2},
+		{"半壊", 1, 2, 1, 1},
+		{"全壊", 1, 1, -1, 0},
+		{"修復", 0, 1, 0, 2},
+		{"自プレイヤーの完全に塗られたマス", 0, 2, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newEmptyLogic()
+			setCell(g, 0, 3, 2, tt.owner, tt.val)
+			if err := g.Progress(0, moves(map[int]int{0: 0})); err != nil {
+				t.Fatal(err)
+			}
+			c := g.GetCell(0, 3, 2)
+			if c.Owner != tt.wantOwner || c.Val != tt.wantVal {
+				t.Errorf("cell = %+v, want owner %d val %d", *c, tt.wantOwner, tt.wantVal)
+			}
+			a := g.Agents[0]
+			if a.I != 0 || a.J != 3 || a.K != 2 || a.D != 0 {
+				t.Errorf("agent = %+v", *a)
+			}
+			checkArea(t, g)
+		})
+	}
+}
+
+// 複数エージェントが同じターンに同一のマスに通常移動した時の挙動 (problem.md)
+func TestPaintConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		// エージェント 0 と同じマス (0, 3, 2) に移動するエージェント
+		other     int
+		owner     int
+		val       int
+		wantOwner int
+		wantVal   int
+	}{
+		{"他プレイヤーとは取得しない", 1, -1, 0, -1, 0},
+		{"他プレイヤーとは半壊しない", 1, 2, 2, 2, 2},
+		{"他プレイヤーとは全壊しない", 1, 2, 1, 2, 1},
+		{"他プレイヤーとでも修復する", 1, 0, 1, 0, 2},
+		{"相手の修復も行われる", 2, 2, 1, 2, 2},
+		{"同じプレイヤーは 1 つとみなして取得する", 5, -1, 0, 0, 2},
+		{"同じプレイヤーは 1 つとみなして半壊する", 5, 1, 2, 1, 1},
+		{"同じプレイヤーは 1 つとみなして全壊する", 5, 1, 1, -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newEmptyLogic()
+			setCell(g, 0, 3, 2, tt.owner, tt.val)
+			setAgent(g, tt.other, 0, 4, 2, 2)
+			if err := g.Progress(0, moves(map[int]int{0: 0, tt.other: 0})); err != nil {
+				t.Fatal(err)
+			}
+			c := g.GetCell(0, 3, 2)
+			if c.Owner != tt.wantOwner || c.Val != tt.wantVal {
+				t.Errorf("cell = %+v, want owner %d val %d", *c, tt.wantOwner, tt.wantVal)
+			}
+			checkArea(t, g)
+		})
+	}
+}
+
+func TestCheckCounter(t *testing.T) {
+	g := &GameLogic{}
+	tests := []struct {
+		counter byte
+		ownerId int
+		idx     int
+		want    bool
+	}{
+		{1 << 0, 0, 0, true},
+		{1<<0 | 1<<5, 0, 5, true},
+		// 同じプレイヤーの 2 エージェントが同じマスに移動した場合は idx が大きい方だけが塗る
+		{1<<0 | 1<<5, 0, 0, false},
+		{1 << 5, 0, 5, true},
+		{1<<0 | 1<<1, 0, 0, false},
+		{1<<1 | 1<<4, 1, 4, true},
+		{1<<2 | 1<<4, 1, 4, false},
+		{1<<0 | 1<<1 | 1<<5, 0, 5, false},
+	}
+	for _, tt := range tests {
+		if got := g.CheckCounter(tt.counter, tt.ownerId, tt.idx); got != tt.want {
+			t.Errorf("CheckCounter(%06b, %d, %d) = %v, want %v", tt.counter, tt.ownerId, tt.idx, got, tt.want)
+		}
+	}
+}
+
+// 5 マス前進の特殊移動は通過した 5 マスを完全に塗る
+func TestSpecialStraight(t *testing.T) {
+	g := newEmptyLogic()
+	setCell(g, 0, 3, 2, 1, 2)
+	setCell(g, 0, 4, 2, 2, 1)
+	if err := g.Progress(0, moves(map[int]int{0: 4})); err != nil {
+		t.Fatal(err)
+	}
+
+	pos := []int{0, 2, 2, 0}
+	for step := 0; step < 5; step++ {
+		MoveForward(pos)
+		c := g.GetCell(pos[0], pos[1], pos[2])
+		if c.Owner != 0 || c.Val != 2 {
+			t.Errorf("step %d %v: cell = %+v, want owner 0 val 2", step, pos, *c)
+		}
+	}
+	a := g.Agents[0]
+	if a.I != pos[0] || a.J != pos[1] || a.K != pos[2] || a.D != pos[3] {
+		t.Errorf("agent = %+v, want %v", *a, pos)
+	}
+	if g.Special[0] != 0 {
+		t.Errorf("Special[0] = %d, want 0", g.Special[0])
+	}
+	if g.Area[0] != 5 || g.Area[1] != 0 || g.Area[2] != 0 {
+		t.Errorf("Area = %v, want [5 0 0]", g.Area)
+	}
+	checkArea(t, g)
+}
+
+// 指定したマスに移動する特殊移動は移動先と隣接する 4 マスを塗り、方向 0 になる
+func TestSpecialTeleport(t *testing.T) {
+	g := newEmptyLogic()
+	setCell(g, 3, 0, 0, 2, 2)
+	if err := g.Progress(0, moves(map[int]int{5: 8 + FieldIdx(3, 0, 0)})); err != nil {
+		t.Fatal(err)
+	}
+
+	cells := [][]int{{3, 0, 0}}
+	for d := 0; d < 4; d++ {
+		cells = append(cells, MoveRotation([]int{3, 0, 0, d}, 0))
+	}
+	for _, p := range cells {
+		c := g.GetCell(p[0], p[1], p[2])
+		if c.Owner != 0 || c.Val != 2 {
+			t.Errorf("%v: cell = %+v, want owner 0 val 2", p, *c)
+		}
+	}
+	a := g.Agents[5]
+	if a.I != 3 || a.J != 0 || a.K != 0 || a.D != 0 {
+		t.Errorf("agent = %+v, want (3, 0, 0) direction 0", *a)
+	}
+	if g.Special[5] != 0 {
+		t.Errorf("Special[5] = %d, want 0", g.Special[5])
+	}
+	checkArea(t, g)
+}
+
+// 複数のプレイヤーが同じマスを特殊取得対象にした場合は変化しない
+func TestSpecialConflict(t *testing.T) {
+	g := newEmptyLogic()
+	setCell(g, 0, 1, 1, 2, 2)
+	target := 8 + FieldIdx(0, 1, 1)
+	// エージェント 1 の瞬間移動先はプレイヤー 1 から見た座標
+	if err := g.Progress(0, moves(map[int]int{0: target, 1: 8 + FieldIdx(Perspective(1).RelFace(0), 1, 1)})); err != nil {
+		t.Fatal(err)
+	}
+	c := g.GetCell(0, 1, 1)
+	if c.Owner != 2 || c.Val != 2 {
+		t.Errorf("cell = %+v, want unchanged owner 2 val 2", *c)
+	}
+	for d := 0; d < 4; d++ {
+		p := MoveRotation([]int{0, 1, 1, d}, 0)
+		if c := g.GetCell(p[0], p[1], p[2]); c.Owner != -1 {
+			t.Errorf("%v: cell = %+v, want unchanged", p, *c)
+		}
+	}
+	checkArea(t, g)
+}
+
+// マスの状態の変化の処理は通常移動 → 特殊移動の順
+func TestSpecialAfterNormal(t *testing.T) {
+	g := newEmptyLogic()
+	// エージェント 1 が通常移動で (0, 3, 2) を取得した後、エージェント 0 の 5 マス前進で上書きされる
+	setAgent(g, 1, 0, 4, 2, 2)
+	if err := g.Progress(0, moves(map[int]int{0: 4, 1: 0})); err != nil {
+		t.Fatal(err)
+	}
+	c := g.GetCell(0, 3, 2)
+	if c.Owner != 0 || c.Val != 2 {
+		t.Errorf("cell = %+v, want owner 0 val 2", *c)
+	}
+	checkArea(t, g)
+}
+
+func TestScoreSecondHalf(t *testing.T) {
+	g := NewInitialGameLogic()
+	for turn := 0; turn < TOTAL_TURN; turn++ {
+		if err := g.Progress(0, moves(nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 移動しない場合は初期位置の 2 マスのまま後半 147 ターン加算される
+	for p := 0; p < 3; p++ {
+		if g.Score[p] != 2*TOTAL_TURN/2 {
+			t.Errorf("Score[%d] = %d, want %d", p, g.Score[p], 2*TOTAL_TURN/2)
+		}
+	}
+	if g.Turn != TOTAL_TURN {
+		t.Errorf("Turn = %d, want %d", g.Turn, TOTAL_TURN)
+	}
+}
+
+// ランダムな移動で 1 ゲーム進めても、Area は常に所有者が決まっているマスの数と一致する
+func TestAreaMatchesField(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for game := 0; game < 20; game++ {
+		g := NewInitialGameLogic()
+		for turn := 0; turn < TOTAL_TURN; turn++ {
+			// エージェント idx の移動は list[Func1(memberId, idx)]
+			memberId := r.Intn(3)
+			list := make([]int, 6)
+			for idx := 0; idx < 6; idx++ {
+				v := r.Intn(4)
+				if g.Special[idx] > 0 && r.Intn(50) == 0 {
+					if r.Intn(2) == 0 {
+						v = 4 + r.Intn(4)
+					} else {
+						v = 8 + r.Intn(6*N*N)
+					}
+				}
+				list[Func1(memberId, idx)] = v
+			}
+			if err := g.Progress(memberId, list); err != nil {
+				t.Fatal(err)
+			}
+			want := countArea(g)
+			if g.Area[0] != want[0] || g.Area[1] != want[1] || g.Area[2] != want[2] {
+				t.Fatalf("game %d turn %d: Area = %v, want %v", game, turn, g.Area, want)
+			}
+			for _, c := range g.Field {
+				if (c.Owner == -1) != (c.Val == 0) || c.Val < 0 || c.Val > 2 {
+					t.Fatalf("game %d turn %d: invalid cell %+v", game, turn, *c)
+				}
+			}
+		}
+	}
+}
+
+func TestProgressInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		special  []int
+		moveList []int
+	}{
+		{"長さが 6 の倍数でない", nil, []int{0, 0, 0, 0, 0}},
+		{"-1 未満", nil, []int{-2, 0, 0, 0, 0, 0}},
+		{"範囲外のマス", nil, []int{0, 0, 0, 0, 0, MaxMove}},
+		{"特殊移動の残り回数が 0", []int{0, 1, 1, 1, 1, 1}, []int{4, 0, 0, 0, 0, 0}},
+		{"瞬間移動の残り回数が 0", []int{1, 1, 1, 1, 1, 0}, []int{0, 0, 0, 0, 0, 8}},
+		{"2 ターン目が無効", []int{1, 1, 1, 1, 1, 1}, []int{4, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewInitialGameLogic()
+			if tt.special != nil {
+				copy(g.Special, tt.special)
+			}
+			before := g.Clone()
+			if err := g.Progress(0, tt.moveList); err == nil {
+				t.Fatal("Progress succeeded, want error")
+			}
+			// 無効なターンは進めない
+			turns := len(tt.moveList) / 6
+			if len(tt.moveList)%6 == 0 && g.Turn != before.Turn+turns-1 {
+				t.Errorf("Turn = %d, want %d", g.Turn, before.Turn+turns-1)
+			}
+			if g.Turn == before.Turn && !sameLogic(g, before) {
+				t.Errorf("Progress modified the board")
+			}
+			checkInvariants(t, g)
+		})
+	}
+}
+
+func sameLogic(a, b *GameLogic) bool {
+	for fi := range a.Field {
+		if *a.Field[fi] != *b.Field[fi] {
+			return false
+		}
+	}
+	for idx := 0; idx < 6; idx++ {
+		if *a.Agents[idx] != *b.Agents[idx] || a.Special[idx] != b.Special[idx] || a.Move[idx] != b.Move[idx] {
+			return false
+		}
+	}
+	for p := 0; p < 3; p++ {
+		if a.Score[p] != b.Score[p] || a.Area[p] != b.Area[p] {
+			return false
+		}
+	}
+	return a.Turn == b.Turn
+}
+
+// 盤面の不変条件
+func checkInvariants(t *testing.T, g *GameLogic) {
+	t.Helper()
+	for fi, c := range g.Field {
+		if c.Owner < -1 || c.Owner > 2 {
+			t.Fatalf("field %d: invalid owner %+v", fi, *c)
+		}
+		if (c.Owner == -1) != (c.Val == 0) || c.Val < 0 || c.Val > 2 {
+			t.Fatalf("field %d: owner and val mismatch %+v", fi, *c)
+		}
+	}
+	for idx, a := range g.Agents {
+		if a.I < 0 || a.I >= 6 || a.J < 0 || a.J >= N || a.K < 0 || a.K >= N || a.D < 0 || a.D >= 4 {
+			t.Fatalf("agent %d: invalid position %+v", idx, *a)
+		}
+		if g.Special[idx] < 0 {
+			t.Fatalf("agent %d: negative special %d", idx, g.Special[idx])
+		}
+	}
+	checkArea(t, g)
+}
+
+// バイト列を先頭から読む (足りない場合は 0)
+type byteReader []byte
+
+func (r *byteReader) next() int {
+	if len(*r) == 0 {
+		return 0
+	}
+	b := (*r)[0]
+	*r = (*r)[1:]
+	return int(b)
+}
+
+// バイト列から任意の盤面を作る
+func decodeLogic(data []byte) *GameLogic {
+	r := byteReader(data)
+	g := NewInitialGameLogic()
+	g.Area = []int{0, 0, 0}
+	for _, c := range g.Field {
+		switch v := r.next() % 7; {
+		case v == 0:
+			c.Owner, c.Val = -1, 0
+		default:
+			c.Owner, c.Val = (v-1)/2, (v-1)%2+1
+			g.Area[c.Owner]++
+		}
+	}
+	for idx, a := range g.Agents {
+		a.I, a.J, a.K, a.D = r.next()%6, r.next()%N, r.next()%N, r.next()%4
+		g.Special[idx] = r.next() % 3
+	}
+	g.Turn = r.next() % TOTAL_TURN
+	for p := 0; p < 3; p++ {
+		g.Score[p] = r.next() * 10
+	}
+	return g
+}
+
+// 2 バイトずつ移動にする (範囲外の -2 と MaxMove も含む)
+func decodeMoves(data []byte) []int {
+	moves := make([]int, len(data)/2)
+	for n := range moves {
+		moves[n] = (int(data[2*n])<<8|int(data[2*n+1]))%(MaxMove+3) - 2
+	}
+	return moves
+}
+
+// 任意の盤面と移動で Progress を実行しても不変条件が保たれる
+// 1 ターンずつ進めた場合と結果が一致し、Score は各ターンの塗られたマスの数を数え直した値だけ増える
+func FuzzProgress(f *testing.F) {
+	f.Add([]byte{}, []byte{0, 0, 0, 1, 0, 2, 0, 3, 0, 4, 0, 5}, 0)
+	f.Add([]byte{1, 2, 3, 4, 5, 6}, []byte{0, 6, 0, 9, 0, 1, 0, 1, 0, 5, 0, 10}, 1)
+	f.Add(make([]byte, 200), []byte{0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 2, 0, 2, 0, 2, 0, 2, 0, 2, 0, 2}, 3)
+	f.Add([]byte{0, 0, 0}, []byte{0, 2, 0, 6, 0, 2, 0, 2, 0, 2, 0, 2}, 0)
+	f.Add([]byte{6, 5, 4, 3}, []byte{0, 0, 0, 1, 0, 2, 0, 3, 0, 4}, 2)
+	f.Fuzz(func(t *testing.T, board, moveData []byte, memberId int) {
+		g := decodeLogic(board)
+		moveList := decodeMoves(moveData)
+		memberId = (memberId%6 + 6) % 6
+
+		// 1 ターンずつ進めて Score の増分を数え直す
+		stepped := g.Clone()
+		score := append([]int{}, g.Score...)
+		turns := 0
+		var stepErr error
+		if len(moveList)%6 == 0 {
+			for i := 0; i < len(moveList); i += 6 {
+				turn := stepped.Turn
+				if stepErr = stepped.Progress(memberId, moveList[i:i+6]); stepErr != nil {
+					break
+				}
+				turns++
+				area := countArea(stepped)
+				if turn >= TOTAL_TURN/2 {
+					for p := 0; p < 3; p++ {
+						score[p] += area[p]
+					}
+				}
+			}
+		}
+
+		before := g.Clone()
+		err := g.Progress(memberId, moveList)
+		if len(moveList)%6 != 0 {
+			if err == nil {
+				t.Fatalf("Progress(%v) succeeded with invalid length", moveList)
+			}
+			if !sameLogic(g, before) {
+				t.Fatal("Progress modified the board on invalid length")
+			}
+			return
+		}
+		if (err == nil) != (stepErr == nil) {
+			t.Fatalf("Progress error = %v, step by step error = %v", err, stepErr)
+		}
+		checkInvariants(t, g)
+		if g.Turn != before.Turn+turns {
+			t.Fatalf("Turn = %d, want %d", g.Turn, before.Turn+turns)
+		}
+		for p := 0; p < 3; p++ {
+			if g.Score[p] != score[p] {
+				t.Fatalf("Score = %v, want %v", g.Score, score)
+			}
+		}
+		if !sameLogic(g, stepped) {
+			t.Fatal("Progress and step by step Progress differ")
+		}
+	})
+}
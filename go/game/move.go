@@ -0,0 +1,52 @@
+package game
+
+// pos ([i, j, k, d]) から rotation 方向に回転して 1 マス前進した位置を返す
+func MoveRotation(pos []int, rotation int) []int {
+	nextPos := make([]int, 4)
+	for i := 0; i < 4; i++ {
+		nextPos[i] = pos[i]
+	}
+	nextPos[3] += rotation
+	nextPos[3] %= 4
+	MoveForward(nextPos)
+	return nextPos
+}
+
+// pos ([i, j, k, d]) を 1 マス前進させる
+func MoveForward(pos []int) {
+	i := pos[0]
+	j := pos[1]
+	k := pos[2]
+	d := pos[3]
+	var jj = j + Dj[d]
+	var kk = k + Dk[d]
+	if jj >= N {
+		pos[0] = i/3*3 + (i%3+1)%3 // [1, 2, 0, 4, 5, 3][i]
+		pos[1] = k
+		pos[2] = N - 1
+		pos[3] = 3
+	} else if jj < 0 {
+		pos[0] = (1-i/3)*3 + (4-i%3)%3 // [4, 3, 5, 1, 0, 2][i]
+		pos[1] = 0
+		pos[2] = N - 1 - k
+		pos[3] = 0
+	} else if kk >= N {
+		pos[0] = i/3*3 + (i%3+2)%3 // [2, 0, 1, 5, 3, 4][i]
+		pos[1] = N - 1
+		pos[2] = j
+		pos[3] = 2
+	} else if kk < 0 {
+		pos[0] = (1-i/3)*3 + (3-i%3)%3 // [3, 5, 4, 0, 2, 1][i]
+		pos[1] = N - 1 - j
+		pos[2] = 0
+		pos[3] = 1
+	} else {
+		pos[1] = jj
+		pos[2] = kk
+	}
+}
+
+// エージェントが同じマスにいるかを判定する
+func IsSamePos(a []int, b []int) bool {
+	return a[0] == b[0] && a[1] == b[1] && a[2] == b[2]
+}
@@ -0,0 +1,155 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 全てのマスと方向の組み合わせ
+func allPositions() [][]int {
+	var positions [][]int
+	for i := 0; i < 6; i++ {
+		for j := 0; j < N; j++ {
+			for k := 0; k < N; k++ {
+				for d := 0; d < 4; d++ {
+					positions = append(positions, []int{i, j, k, d})
+				}
+			}
+		}
+	}
+	return positions
+}
+
+func samePosDir(a, b []int) bool {
+	return IsSamePos(a, b) && a[3] == b[3]
+}
+
+func TestMoveForward(t *testing.T) {
+	tests := []struct {
+		name string
+		pos  []int
+		want []int
+	}{
+		{"interior", []int{0, 2, 2, 0}, []int{0, 3, 2, 0}},
+		{"interior d1", []int{0, 2, 2, 1}, []int{0, 2, 3, 1}},
+		{"interior d2", []int{0, 2, 2, 2}, []int{0, 1, 2, 2}},
+		{"interior d3", []int{0, 2, 2, 3}, []int{0, 2, 1, 3}},
+		{"j >= N", []int{0, 4, 2, 0}, []int{1, 2, 4, 3}},
+		{"j < 0", []int{0, 0, 2, 2}, []int{4, 0, 2, 0}},
+		{"k >= N", []int{0, 2, 4, 1}, []int{2, 4, 2, 2}},
+		{"k < 0", []int{0, 2, 0, 3}, []int{3, 2, 0, 1}},
+		{"face 5 j >= N", []int{5, 4, 1, 0}, []int{3, 1, 4, 3}},
+		{"corner", []int{2, 4, 4, 0}, []int{0, 4, 4, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := append([]int{}, tt.pos...)
+			MoveForward(pos)
+			if !samePosDir(pos, tt.want) {
+				t.Errorf("MoveForward(%v) = %v, want %v", tt.pos, pos, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveRotation(t *testing.T) {
+	tests := []struct {
+		pos      []int
+		rotation int
+		want     []int
+	}{
+		{[]int{0, 2, 2, 0}, 0, []int{0, 3, 2, 0}},
+		{[]int{0, 2, 2, 0}, 1, []int{0, 2, 3, 1}},
+		{[]int{0, 2, 2, 0}, 2, []int{0, 1, 2, 2}},
+		{[]int{0, 2, 2, 0}, 3, []int{0, 2, 1, 3}},
+		{[]int{0, 2, 2, 3}, 1, []int{0, 3, 2, 0}},
+		{[]int{0, 4, 2, 3}, 1, []int{1, 2, 4, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.pos, tt.rotation), func(t *testing.T) {
+			orig := append([]int{}, tt.pos...)
+			got := MoveRotation(tt.pos, tt.rotation)
+			if !samePosDir(got, tt.want) {
+				t.Errorf("MoveRotation(%v, %d) = %v, want %v", tt.pos, tt.rotation, got, tt.want)
+			}
+			if !samePosDir(tt.pos, orig) {
+				t.Errorf("MoveRotation modified pos: %v", tt.pos)
+			}
+		})
+	}
+}
+
+// 移動先は常に有効なマスで、前進して 180 度回転して前進すると元のマスに逆向きで戻る
+func TestMoveForwardReversible(t *testing.T) {
+	for _, pos := range allPositions() {
+		next := MoveRotation(pos, 0)
+		if next[0] < 0 || next[0] >= 6 || next[1] < 0 || next[1] >= N || next[2] < 0 || next[2] >= N || next[3] < 0 || next[3] >= 4 {
+			t.Fatalf("MoveForward(%v) = %v: invalid position", pos, next)
+		}
+		back := MoveRotation(next, 2)
+		want := []int{pos[0], pos[1], pos[2], (pos[3] + 2) % 4}
+		if !samePosDir(back, want) {
+			t.Errorf("%v -> %v -> %v, want %v", pos, next, back, want)
+		}
+	}
+}
+
+// 各マスの 4 方向の隣接マスは全て異なる
+func TestNeighborsDistinct(t *testing.T) {
+	for i := 0; i < 6; i++ {
+		for j := 0; j < N; j++ {
+			for k := 0; k < N; k++ {
+				seen := map[int]bool{FieldIdx(i, j, k): true}
+				for d := 0; d < 4; d++ {
+					next := MoveRotation([]int{i, j, k, d}, 0)
+					fi := FieldIdx(next[0], next[1], next[2])
+					if seen[fi] {
+						t.Errorf("(%d, %d, %d) direction %d: duplicated neighbor %v", i, j, k, d, next)
+					}
+					seen[fi] = true
+				}
+			}
+		}
+	}
+}
+
+// まっすぐ 20 マス前進すると、N マスごとに別の面を通って同じ向きで元のマスに戻る
+func TestStraightLoop(t *testing.T) {
+	for _, start := range allPositions() {
+		pos := append([]int{}, start...)
+		visited := map[int]bool{}
+		faces := map[int]bool{}
+		for step := 1; step <= 4*N; step++ {
+			MoveForward(pos)
+			fi := FieldIdx(pos[0], pos[1], pos[2])
+			if step < 4*N && visited[fi] {
+				t.Fatalf("%v: visited %v twice in %d steps", start, pos, step)
+			}
+			visited[fi] = true
+			if step%N == 0 {
+				// N マス進むごとに面を 1 つ通り過ぎる
+				faces[pos[0]] = true
+			}
+		}
+		if !samePosDir(pos, start) {
+			t.Errorf("%v: 20 steps ended at %v", start, pos)
+		}
+		if len(faces) != 4 {
+			t.Errorf("%v: passed %d faces, want 4", start, len(faces))
+		}
+	}
+}
+
+// GameLogic.MoveForward と MoveForward は常に同じ位置に移動する
+func TestMoveForwardImplementationsAgree(t *testing.T) {
+	for _, pos := range allPositions() {
+		g := &GameLogic{Agents: []*Agent{{I: pos[0], J: pos[1], K: pos[2], D: pos[3]}}}
+		g.MoveForward(0)
+		got := []int{g.Agents[0].I, g.Agents[0].J, g.Agents[0].K, g.Agents[0].D}
+		want := append([]int{}, pos...)
+		MoveForward(want)
+		if !samePosDir(got, want) {
+			t.Errorf("%v: GameLogic.MoveForward = %v, MoveForward = %v", pos, got, want)
+		}
+	}
+}
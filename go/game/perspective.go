@@ -0,0 +1,131 @@
+package game
+
+// プレイヤー p (0-2) から見た座標系と絶対座標系 (プレイヤー 0 から見た座標系) の変換
+//
+// 移動APIのレスポンスは各プレイヤーから見た座標系で、自エージェントが 0 と 5、自プレイヤーが 0、
+// 自エージェントの初期位置の面が 0 と 5 になる。
+// 座標系の間の変換は面、エージェント、プレイヤーの番号の付け替えだけで、(j, k) と方向 d は変わらない。
+// 変換は合成でき、プレイヤー q から見た座標系でのプレイヤー o の座標系は、絶対座標系での (q+o)%3 の座標系になる。
+//
+// 移動の値のうち、指定したマスに移動する特殊移動の (i, j, k) は移動したエージェントのプレイヤーから見た座標で、
+// どの座標系の move でも同じ値になる (GameLogic.Progress もこの値を受け取る)。
+type Perspective int
+
+// p から見た面 i を絶対座標系の面にする
+func (p Perspective) AbsFace(i int) int {
+	return Func1(int(p), i)
+}
+
+// 絶対座標系の面 i を p から見た面にする
+func (p Perspective) RelFace(i int) int {
+	return Func1((3-int(p))%3, i)
+}
+
+// p から見たエージェントの番号を絶対座標系の番号にする
+func (p Perspective) AbsAgent(idx int) int {
+	return Func1(int(p), idx)
+}
+
+// 絶対座標系のエージェントの番号を p から見た番号にする
+func (p Perspective) RelAgent(idx int) int {
+	return Func1((3-int(p))%3, idx)
+}
+
+// p から見たプレイヤーの番号を絶対座標系の番号にする (-1 は -1 のまま)
+func (p Perspective) AbsPlayer(o int) int {
+	if o < 0 {
+		return o
+	}
+	return (int(p) + o) % 3
+}
+
+// 絶対座標系のプレイヤーの番号を p から見た番号にする (-1 は -1 のまま)
+func (p Perspective) RelPlayer(o int) int {
+	if o < 0 {
+		return o
+	}
+	return (o - int(p) + 3) % 3
+}
+
+// p から見たエージェントの位置 [i, j, k, d] を絶対座標系にする
+func (p Perspective) AbsPos(pos []int) []int {
+	return []int{p.AbsFace(pos[0]), pos[1], pos[2], pos[3]}
+}
+
+// 絶対座標系のエージェントの位置 [i, j, k, d] を p から見た位置にする
+func (p Perspective) RelPos(pos []int) []int {
+	return []int{p.RelFace(pos[0]), pos[1], pos[2], pos[3]}
+}
+
+// p のエージェントが指定したマスに移動する特殊移動の値 (p から見た座標) を、絶対座標系の座標の値にする
+// 指定したマスに移動する特殊移動でない値はそのまま返す
+func (p Perspective) AbsTeleport(v int) int {
+	if v < 8 {
+		return v
+	}
+	m := v - 8
+	return 8 + FieldIdx(p.AbsFace(m/(N*N)), m/N%N, m%N)
+}
+
+// 絶対座標系の座標の指定したマスに移動する特殊移動の値を、p から見た座標の値にする
+func (p Perspective) RelTeleport(v int) int {
+	if v < 8 {
+		return v
+	}
+	m := v - 8
+	return 8 + FieldIdx(p.RelFace(m/(N*N)), m/N%N, m%N)
+}
+
+// p から見たエージェントの順の移動を絶対座標系の順にする (値はそのまま)
+func (p Perspective) AbsMoves(moves []int) []int {
+	result := make([]int, len(moves))
+	for idx, v := range moves {
+		result[p.AbsAgent(idx)] = v
+	}
+	return result
+}
+
+// 絶対座標系のエージェントの順の移動を p から見た順にする (値はそのまま)
+// GameLogic.Progress(p, moves) は moves をこの順に並べ替えて適用する
+func (p Perspective) RelMoves(moves []int) []int {
+	result := make([]int, len(moves))
+	for idx := range moves {
+		result[idx] = moves[p.AbsAgent(idx)]
+	}
+	return result
+}
+
+// 盤面 g の面、エージェント、プレイヤーの番号をそれぞれ face, agent, player で付け替えた盤面を作る
+func transformLogic(g *GameLogic, face func(int) int, agent func(int) int, player func(int) int) *GameLogic {
+	next := g.Clone()
+	for i := 0; i < 6; i++ {
+		for j := 0; j < N; j++ {
+			for k := 0; k < N; k++ {
+				c := g.GetCell(i, j, k)
+				*next.GetCell(face(i), j, k) = Cell{Owner: player(c.Owner), Val: c.Val}
+			}
+		}
+	}
+	for idx := 0; idx < 6; idx++ {
+		a := g.Agents[idx]
+		to := agent(idx)
+		*next.Agents[to] = Agent{I: face(a.I), J: a.J, K: a.K, D: a.D}
+		next.Move[to] = g.Move[idx]
+		next.Special[to] = g.Special[idx]
+	}
+	for o := 0; o < 3; o++ {
+		next.Score[player(o)] = g.Score[o]
+		next.Area[player(o)] = g.Area[o]
+	}
+	return next
+}
+
+// p から見た盤面を絶対座標系の盤面にする
+func (p Perspective) AbsLogic(g *GameLogic) *GameLogic {
+	return transformLogic(g, p.AbsFace, p.AbsAgent, p.AbsPlayer)
+}
+
+// 絶対座標系の盤面を p から見た盤面にする
+func (p Perspective) RelLogic(g *GameLogic) *GameLogic {
+	return transformLogic(g, p.RelFace, p.RelAgent, p.RelPlayer)
+}
@@ -0,0 +1,132 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPerspectiveInverse(t *testing.T) {
+	for p := Perspective(0); p < 3; p++ {
+		for x := 0; x < 6; x++ {
+			if got := p.RelFace(p.AbsFace(x)); got != x {
+				t.Errorf("p=%d: RelFace(AbsFace(%d)) = %d", p, x, got)
+			}
+			if got := p.RelAgent(p.AbsAgent(x)); got != x {
+				t.Errorf("p=%d: RelAgent(AbsAgent(%d)) = %d", p, x, got)
+			}
+			// エージェントのプレイヤーは座標系によらない
+			if Agent2Player(p.AbsAgent(x)) != p.AbsPlayer(Agent2Player(x)) {
+				t.Errorf("p=%d: agent %d belongs to player %d, want %d", p, x, Agent2Player(p.AbsAgent(x)), p.AbsPlayer(Agent2Player(x)))
+			}
+		}
+		for o := -1; o < 3; o++ {
+			if got := p.RelPlayer(p.AbsPlayer(o)); got != o {
+				t.Errorf("p=%d: RelPlayer(AbsPlayer(%d)) = %d", p, o, got)
+			}
+		}
+		for v := -1; v < MaxMove; v++ {
+			if got := p.RelTeleport(p.AbsTeleport(v)); got != v {
+				t.Errorf("p=%d: RelTeleport(AbsTeleport(%d)) = %d", p, v, got)
+			}
+		}
+		moves := []int{0, 1, 2, 3, 4, 5}
+		if got := p.RelMoves(p.AbsMoves(moves)); !sameInts(got, moves) {
+			t.Errorf("p=%d: RelMoves(AbsMoves(%v)) = %v", p, moves, got)
+		}
+	}
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 自エージェントは自分から見た座標系で 0 と 5 で、初期位置は面 0 と面 5
+func TestPerspectiveOwnAgents(t *testing.T) {
+	g := NewInitialGameLogic()
+	for p := Perspective(0); p < 3; p++ {
+		for _, idx := range []int{0, 5} {
+			abs := p.AbsAgent(idx)
+			if Agent2Player(abs) != int(p) {
+				t.Errorf("p=%d: agent %d is agent %d of player %d", p, idx, abs, Agent2Player(abs))
+			}
+			pos := p.RelPos([]int{g.Agents[abs].I, g.Agents[abs].J, g.Agents[abs].K, g.Agents[abs].D})
+			if pos[0] != idx {
+				t.Errorf("p=%d: agent %d starts at face %d", p, idx, pos[0])
+			}
+		}
+		// 初期盤面はどのプレイヤーから見ても同じ
+		if rel := p.RelLogic(g); !sameLogic(rel, g) {
+			t.Errorf("p=%d: initial board differs", p)
+		}
+	}
+}
+
+// 指定したマスに移動する特殊移動の座標は移動したエージェントのプレイヤーから見た座標
+func TestPerspectiveTeleport(t *testing.T) {
+	g := NewInitialGameLogic()
+	p := Perspective(1)
+	// プレイヤー 1 から見た (0, 2, 2) はプレイヤー 1 のエージェント 0 の初期位置
+	v := 8 + FieldIdx(0, 2, 2)
+	abs := p.AbsTeleport(v)
+	if abs != 8+FieldIdx(1, 2, 2) {
+		t.Errorf("AbsTeleport(%d) = %d, want %d", v, abs, 8+FieldIdx(1, 2, 2))
+	}
+	moves := p.AbsMoves([]int{-1, -1, -1, -1, -1, v})
+	if err := g.Progress(0, moves); err != nil {
+		t.Fatal(err)
+	}
+	a := g.Agents[p.AbsAgent(5)]
+	if a.I != 1 || a.J != 2 || a.K != 2 {
+		t.Errorf("agent = %+v, want (1, 2, 2)", *a)
+	}
+}
+
+// 絶対座標系で進めてから変換した盤面と、変換してから進めた盤面は一致する
+func TestPerspectiveProgress(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	for game := 0; game < 10; game++ {
+		g := NewInitialGameLogic()
+		for g.Turn < TOTAL_TURN {
+			moves := randomMoves(r)
+			for idx, v := range moves {
+				if v >= 4 && g.Special[idx] <= 0 || v >= MaxMove {
+					moves[idx] = r.Intn(4)
+				}
+			}
+			var rels, reordered []*GameLogic
+			for p := Perspective(0); p < 3; p++ {
+				rels = append(rels, p.RelLogic(g))
+				reordered = append(reordered, p.RelLogic(g))
+			}
+			if err := g.Progress(0, moves[:]); err != nil {
+				t.Fatal(err)
+			}
+			for p := Perspective(0); p < 3; p++ {
+				if err := rels[p].Progress(0, p.RelMoves(moves[:])); err != nil {
+					t.Fatal(err)
+				}
+				if err := reordered[p].Progress(int(p), moves[:]); err != nil {
+					t.Fatal(err)
+				}
+				want := p.RelLogic(g)
+				if !sameLogic(rels[p], want) {
+					t.Fatalf("turn %d p=%d: Progress(0, RelMoves(moves)) differs", g.Turn, p)
+				}
+				if !sameLogic(reordered[p], want) {
+					t.Fatalf("turn %d p=%d: Progress(p, moves) differs", g.Turn, p)
+				}
+				if !sameLogic(p.AbsLogic(want), g) {
+					t.Fatalf("turn %d p=%d: AbsLogic(RelLogic(g)) differs", g.Turn, p)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package geometry は立方体の 150 マスの隣接関係とマス間の距離を事前に計算した表を提供する
+// 毎ターンの評価で行っていた幅優先探索を表の参照に置き換えるために使う
+package geometry
+
+import "tenka/game"
+
+// マスの数
+// マスは game.FieldIdx の番号で表す
+const Cells = 6 * game.N * game.N
+
+// 2 マス間の最短距離の最大値 (init で計算する)
+var MaxDistance int
+
+var (
+	// dist[a][b] はマス a からマス b に移動するのに必要な最小のターン数
+	dist [Cells][Cells]uint8
+	// firstSteps[a][b] はマス a から b への最短経路の最初の移動方向 (a での向き) の集合 (ビット d が方向 d)
+	firstSteps [Cells][Cells]uint8
+	// rings[c][n] はマス c からの最短距離 (dist) がちょうど n のマス
+	rings [Cells][][]int
+)
+
+func init() {
+	queue := make([]int, 0, Cells)
+	for src := 0; src < Cells; src++ {
+		var visited [Cells]bool
+		visited[src] = true
+		queue = append(queue[:0], src)
+		for n := 0; n < len(queue); n++ {
+			c := queue[n]
+			for d := 0; d < 4; d++ {
+				nc, _ := game.Forward(c, d)
+				if !visited[nc] {
+					visited[nc] = true
+					dist[src][nc] = dist[src][c] + 1
+					queue = append(queue, nc)
+				}
+			}
+		}
+		rings[src] = make([][]int, dist[src][queue[len(queue)-1]]+1)
+		for _, c := range queue {
+			rings[src][dist[src][c]] = append(rings[src][dist[src][c]], c)
+		}
+		if len(rings[src])-1 > MaxDistance {
+			MaxDistance = len(rings[src]) - 1
+		}
+	}
+
+	for a := 0; a < Cells; a++ {
+		for d := 0; d < 4; d++ {
+			nc, _ := game.Forward(a, d)
+			for b := 0; b < Cells; b++ {
+				if b != a && dist[nc][b]+1 == dist[a][b] {
+					firstSteps[a][b] |= 1 << d
+				}
+			}
+		}
+	}
+}
+
+// (i, j, k) のマスの番号
+// pos は [i, j, k] または [i, j, k, d]
+func Index(pos []int) int {
+	return game.FieldIdx(pos[0], pos[1], pos[2])
+}
+
+// マスの番号を (i, j, k) に戻す
+func Coord(c int) (i, j, k int) {
+	return c / game.N / game.N, c / game.N % game.N, c % game.N
+}
+
+// マス c で方向 d を向いた状態から rotation 方向に回転して 1 マス前進した先のマスと方向
+// game.MoveRotation と同じ
+func Move(c, d, rotation int) (int, int) {
+	return game.Forward(c, (d+rotation)%4)
+}
+
+// マス c に隣接する 4 マス (方向 d に前進した先が d 番目)
+func Neighbors(c int) [4]int {
+	var result [4]int
+	for d := 0; d < 4; d++ {
+		result[d], _ = game.Forward(c, d)
+	}
+	return result
+}
+
+// マス a からマス b に移動するのに必要な最小のターン数
+func Distance(a, b int) int {
+	return int(dist[a][b])
+}
+
+// マス a で方向 d を向いた状態から rotation 方向に回転して 1 マス前進すると、b への最短経路に乗るか
+// a == b の場合は false
+func OnShortestPath(a, d, rotation, b int) bool {
+	return firstSteps[a][b]&(1<<((d+rotation)%4)) != 0
+}
+
+// マス c からの最短距離 (Distance) がちょうど n のマス
+// n ターンで到達できるマス全体ではない (最短距離が n 未満で、回り道をして n ターンで到達できるマスは含まない)
+// 返すスライスは共有しているので変更しない
+func Ring(c, n int) []int {
+	if n < 0 || n >= len(rings[c]) {
+		return nil
+	}
+	return rings[c][n]
+}
@@ -0,0 +1,259 @@
+package geometry
+
+import (
+	"testing"
+
+	"tenka/game"
+)
+
+// 以下は表を使う前に毎ターン行っていた幅優先探索 (比較用)
+
+func createMap() [][][]int {
+	m := make([][][]int, 6)
+	for i := 0; i < 6; i++ {
+		m[i] = make([][]int, game.N)
+		for j := 0; j < game.N; j++ {
+			m[i][j] = make([]int, game.N)
+		}
+	}
+	return m
+}
+
+// pos からの距離がちょうど l の agents の添え字
+func bfsInLength(pos []int, l int, agents [][]int) []int {
+	var result []int
+	visited := createMap()
+	visited[pos[0]][pos[1]][pos[2]] = -1
+	if l == 0 {
+		for agent, agentPos := range agents {
+			if game.IsSamePos(pos, agentPos) {
+				result = append(result, agent)
+			}
+		}
+		return result
+	}
+	current := [][]int{pos}
+	var next [][]int
+	for length := 1; length <= l; length++ {
+		for _, pos := range current {
+			for d := 0; d < 4; d++ {
+				nextPos := game.MoveRotation(pos, d)
+				if visited[nextPos[0]][nextPos[1]][nextPos[2]] == 0 {
+					visited[nextPos[0]][nextPos[1]][nextPos[2]] = length
+					next = append(next, nextPos)
+					if length == l {
+						for agent, agentPos := range agents {
+							if game.IsSamePos(nextPos, agentPos) {
+								result = append(result, agent)
+							}
+						}
+					}
+				}
+			}
+		}
+		current = next
+		next = nil
+	}
+	return result
+}
+
+func bfsDirectionLengthMap(pos []int) [][][][]int {
+	maps := make([][][][]int, 4)
+	for d := 0; d < 4; d++ {
+		maps[d] = createMap()
+		maps[d][pos[0]][pos[1]][pos[2]] = -1
+	}
+	current := make([][][]int, 4)
+	for d := 0; d < 4; d++ {
+		nextPos := game.MoveRotation(pos, d)
+		maps[d][nextPos[0]][nextPos[1]][nextPos[2]] = 1
+		current[d] = append(current[d], nextPos)
+	}
+	next := make([][][]int, 4)
+	for length := 2; ; length++ {
+		for dd := 0; dd < 4; dd++ {
+			for _, pos := range current[dd] {
+				for i := 0; i < 4; i++ {
+					nextPos := game.MoveRotation(pos, i)
+					found := false
+					for _, m := range maps {
+						if m[nextPos[0]][nextPos[1]][nextPos[2]] != 0 {
+							found = true
+							break
+						}
+					}
+					if !found {
+						next[dd] = append(next[dd], nextPos)
+					}
+				}
+			}
+		}
+		for dd := 0; dd < 4; dd++ {
+			for _, v := range next[dd] {
+				maps[dd][v[0]][v[1]][v[2]] = length
+			}
+		}
+		if len(next[0]) == 0 && len(next[1]) == 0 && len(next[2]) == 0 && len(next[3]) == 0 {
+			break
+		}
+		current = next
+		next = make([][][]int, 4)
+	}
+	return maps
+}
+
+// 表から作った CreateDirectionLengthMap と同じ形式の距離
+func tableDirectionLengthMap(pos []int) [][][][]int {
+	c := Index(pos)
+	maps := make([][][][]int, 4)
+	for d := 0; d < 4; d++ {
+		maps[d] = createMap()
+		maps[d][pos[0]][pos[1]][pos[2]] = -1
+	}
+	for n := 1; n <= MaxDistance; n++ {
+		for _, b := range Ring(c, n) {
+			i, j, k := Coord(b)
+			for d := 0; d < 4; d++ {
+				if OnShortestPath(c, pos[3], d, b) {
+					maps[d][i][j][k] = n
+				}
+			}
+		}
+	}
+	return maps
+}
+
+func allPositions() [][]int {
+	var positions [][]int
+	for c := 0; c < Cells; c++ {
+		i, j, k := Coord(c)
+		for d := 0; d < 4; d++ {
+			positions = append(positions, []int{i, j, k, d})
+		}
+	}
+	return positions
+}
+
+func TestIndex(t *testing.T) {
+	for c := 0; c < Cells; c++ {
+		i, j, k := Coord(c)
+		if Index([]int{i, j, k}) != c || game.FieldIdx(i, j, k) != c {
+			t.Errorf("Coord(%d) = (%d, %d, %d)", c, i, j, k)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	for _, pos := range allPositions() {
+		for r := 0; r < 4; r++ {
+			want := game.MoveRotation(pos, r)
+			c, d := Move(Index(pos), pos[3], r)
+			if c != Index(want) || d != want[3] {
+				t.Errorf("Move(%v, %d) = (%d, %d), want %v", pos, r, c, d, want)
+			}
+			if Neighbors(Index(pos))[(pos[3]+r)%4] != c {
+				t.Errorf("Neighbors(%v) does not contain %d", pos, c)
+			}
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if MaxDistance <= 0 || MaxDistance >= Cells {
+		t.Fatalf("MaxDistance = %d", MaxDistance)
+	}
+	for a := 0; a < Cells; a++ {
+		count := 0
+		for n := 0; n <= MaxDistance+1; n++ {
+			for _, b := range Ring(a, n) {
+				if Distance(a, b) != n {
+					t.Fatalf("Ring(%d, %d) contains %d at distance %d", a, n, b, Distance(a, b))
+				}
+				count++
+			}
+		}
+		if count != Cells {
+			t.Fatalf("rings of %d contain %d cells, want %d", a, count, Cells)
+		}
+		for b := 0; b < Cells; b++ {
+			if Distance(a, b) != Distance(b, a) {
+				t.Fatalf("Distance(%d, %d) = %d, Distance(%d, %d) = %d", a, b, Distance(a, b), b, a, Distance(b, a))
+			}
+		}
+	}
+}
+
+// 表の距離は幅優先探索と一致する
+func TestDistanceMatchesBFS(t *testing.T) {
+	var agents [][]int
+	for c := 0; c < Cells; c++ {
+		i, j, k := Coord(c)
+		agents = append(agents, []int{i, j, k, 0})
+	}
+	for _, pos := range agents {
+		for l := 0; l <= MaxDistance+1; l++ {
+			want := bfsInLength(pos, l, agents)
+			got := Ring(Index(pos), l)
+			if len(got) != len(want) {
+				t.Fatalf("%v: Ring(%d) = %v, want %v", pos, l, got, want)
+			}
+			for _, c := range want {
+				if Distance(Index(pos), c) != l {
+					t.Fatalf("%v: Distance to %d = %d, want %d", pos, c, Distance(Index(pos), c), l)
+				}
+			}
+		}
+	}
+}
+
+// 表から作った方向ごとの距離は幅優先探索と一致する
+func TestDirectionLengthMapMatchesBFS(t *testing.T) {
+	for _, pos := range allPositions() {
+		want := bfsDirectionLengthMap(pos)
+		got := tableDirectionLengthMap(pos)
+		for d := 0; d < 4; d++ {
+			for i := 0; i < 6; i++ {
+				for j := 0; j < game.N; j++ {
+					for k := 0; k < game.N; k++ {
+						if got[d][i][j][k] != want[d][i][j][k] {
+							t.Fatalf("%v rotation %d (%d, %d, %d): got %d, want %d", pos, d, i, j, k, got[d][i][j][k], want[d][i][j][k])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+var benchAgents = [][]int{{0, 2, 2, 0}, {1, 0, 3, 1}, {2, 4, 4, 2}, {3, 1, 0, 3}, {4, 2, 1, 0}, {5, 3, 3, 1}}
+
+func BenchmarkInLengthBFS(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		for l := 0; l <= 2; l++ {
+			bfsInLength(benchAgents[0], l, benchAgents[1:5])
+		}
+	}
+}
+
+func BenchmarkInLengthTable(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		for l := 0; l <= 2; l++ {
+			c := Index(benchAgents[0])
+			for _, a := range benchAgents[1:5] {
+				_ = Distance(c, Index(a)) == l
+			}
+		}
+	}
+}
+
+func BenchmarkDirectionLengthMapBFS(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		bfsDirectionLengthMap(benchAgents[n%6])
+	}
+}
+
+func BenchmarkDirectionLengthMapTable(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		tableDirectionLengthMap(benchAgents[n%6])
+	}
+}
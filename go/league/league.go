@@ -0,0 +1,307 @@
+// Package league は予選リーグのマッチング、クラス内得点、昇格・降格によるランキングの決定方法を再現する
+// ゲームの実行は含まないので、Matching で決めたゲームを実行して結果を Record に渡す
+package league
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"tenka/rating"
+)
+
+const (
+	// 1 回のマッチングで 1 人あたりが参加するゲーム数
+	GamesPerMatching = 4
+	// クラス内得点の計算に使う直近のマッチング数の範囲
+	MinScoreMatchings = 2
+	MaxScoreMatchings = 5
+)
+
+// クラス n の定員
+func ClassCapacity(n int) int {
+	if n == 1 {
+		return 12
+	}
+	return 6 * n
+}
+
+// クラス n からの昇格者数と降格者数
+func promotions(n int) int { return n - 1 }
+func demotions(n int) int  { return n }
+
+// 現在のクラスに入った理由
+type entrance int
+
+const (
+	enteredNew entrance = iota
+	enteredPromoted
+	enteredDemoted
+)
+
+// 参加したマッチングでのクラスと順位点合計
+type entry struct {
+	class  int
+	points float64
+}
+
+// 参加者
+type Participant struct {
+	Id   int
+	Name string
+	// 現在のクラス (ランキングにいない場合は 0)
+	Class int
+	// 前回のマッチングで計算したクラス内得点 (HasScore が false の場合は N/A)
+	Score    float64
+	HasScore bool
+	// ランキングに参加してからの各マッチングの結果
+	history []entry
+	entered entrance
+	playing bool
+	current float64
+	promote bool
+	demote  bool
+}
+
+// ランキングに参加してから参加したマッチング数
+func (p *Participant) Matchings() int {
+	return len(p.history)
+}
+
+// 1 ゲームの参加者
+// nil はランダムに行動するエージェント
+type Game struct {
+	Class   int
+	Players [3]*Participant
+}
+
+type League struct {
+	// 現在のランキング (上位から順)
+	Ranking []*Participant
+	// 行ったマッチングの数
+	Matchings int
+	rand      *rand.Rand
+}
+
+func New(r *rand.Rand) *League {
+	return &League{rand: r}
+}
+
+// クラス内得点を計算する
+// 直近 n マッチングが全て現在のクラスである場合の順位点合計 x_n について x_n / √n の最大値とし、
+// 直近 2 マッチングが同じクラスでなければ N/A とする
+func (p *Participant) calcScore() {
+	p.HasScore = false
+	sum := 0.0
+	for n := 1; n <= MaxScoreMatchings && n <= len(p.history); n++ {
+		e := p.history[len(p.history)-n]
+		if e.class != p.Class {
+			break
+		}
+		sum += e.points
+		if n < MinScoreMatchings {
+			continue
+		}
+		score := sum / math.Sqrt(float64(n))
+		if !p.HasScore || score > p.Score {
+			p.Score = score
+			p.HasScore = true
+		}
+	}
+}
+
+// クラス内での順位の比較に使うグループ
+// 降格者は上位、昇格者・新規参加者は下位とする
+func (p *Participant) group() int {
+	if p.HasScore {
+		return 1
+	}
+	if p.entered == enteredDemoted {
+		return 0
+	}
+	return 2
+}
+
+// マッチングを行い、今回のマッチングで行うゲームを返す
+// joined は前回のマッチング以降にマッチング参加APIを実行した参加者で、マッチング参加APIを実行した順に並べる
+// (新規参加者は joined の順にランキングの最下位に追加するので、先に実行した新規参加者が上位になる)
+// last が true の場合は予選リーグの最後のマッチングとして昇格・降格処理とランキング除外処理を行わない
+func (l *League) Matching(joined []*Participant, last bool) []Game {
+	l.Matchings++
+
+	// 前回のマッチングの結果を記録する
+	for _, p := range l.Ranking {
+		if p.playing {
+			p.history = append(p.history, entry{class: p.Class, points: p.current})
+			p.playing = false
+			p.current = 0
+		}
+	}
+
+	// クラス内得点の計算とクラス内の順位の決定
+	prevRank := make(map[*Participant]int, len(l.Ranking))
+	for r, p := range l.Ranking {
+		prevRank[p] = r
+		p.calcScore()
+		p.promote = false
+		p.demote = false
+	}
+	var classes [][]*Participant
+	for _, p := range l.Ranking {
+		for len(classes) < p.Class {
+			classes = append(classes, nil)
+		}
+		classes[p.Class-1] = append(classes[p.Class-1], p)
+	}
+	for _, members := range classes {
+		sort.SliceStable(members, func(a, b int) bool {
+			pa, pb := members[a], members[b]
+			if pa.group() != pb.group() {
+				return pa.group() < pb.group()
+			}
+			if pa.HasScore && pa.Score != pb.Score {
+				return pa.Score > pb.Score
+			}
+			return prevRank[pa] < prevRank[pb]
+		})
+	}
+
+	// 昇格・降格処理
+	// 昇格者は上のクラスの最下位、降格者は下のクラスの最上位に移動する
+	if !last {
+		for c, members := range classes {
+			n := c + 1
+			var scored []*Participant
+			for _, p := range members {
+				if p.HasScore {
+					scored = append(scored, p)
+				}
+			}
+			np := promotions(n)
+			if np > len(scored) {
+				np = len(scored)
+			}
+			for _, p := range scored[:np] {
+				p.promote = true
+			}
+			nd := demotions(n)
+			if nd > len(scored)-np {
+				nd = len(scored) - np
+			}
+			for _, p := range scored[len(scored)-nd:] {
+				p.demote = true
+			}
+		}
+	}
+	var ranking []*Participant
+	for c := 0; c <= len(classes); c++ {
+		if c > 0 {
+			for _, p := range classes[c-1] {
+				if p.demote {
+					ranking = append(ranking, p)
+				}
+			}
+		}
+		if c < len(classes) {
+			for _, p := range classes[c] {
+				if !p.promote && !p.demote {
+					ranking = append(ranking, p)
+				}
+			}
+		}
+		if c+1 < len(classes) {
+			for _, p := range classes[c+1] {
+				if p.promote {
+					ranking = append(ranking, p)
+				}
+			}
+		}
+	}
+
+	// ランキング除外処理
+	isJoined := make(map[*Participant]bool, len(joined))
+	for _, p := range joined {
+		isJoined[p] = true
+	}
+	if !last {
+		kept := ranking[:0]
+		for _, p := range ranking {
+			if isJoined[p] {
+				kept = append(kept, p)
+			} else {
+				p.Class = 0
+				p.HasScore = false
+				p.history = nil
+			}
+		}
+		ranking = kept
+	}
+
+	// 新規参加者はランキングの最下位に joined の順 (マッチング参加APIを実行した順) で追加する
+	inRanking := make(map[*Participant]bool, len(ranking))
+	for _, p := range ranking {
+		inRanking[p] = true
+	}
+	for _, p := range joined {
+		if !inRanking[p] {
+			p.Class = 0
+			p.HasScore = false
+			p.history = nil
+			ranking = append(ranking, p)
+			inRanking[p] = true
+		}
+	}
+
+	// 順位確定・クラス決定処理
+	class, left := 1, ClassCapacity(1)
+	for _, p := range ranking {
+		if left == 0 {
+			class++
+			left = ClassCapacity(class)
+		}
+		left--
+		if p.Class == 0 {
+			p.entered = enteredNew
+		} else if class < p.Class {
+			p.entered = enteredPromoted
+		} else if class > p.Class {
+			p.entered = enteredDemoted
+		}
+		p.Class = class
+	}
+	l.Ranking = ranking
+
+	// ゲーム参加者決定処理
+	// 同じクラスの参加者で 1 人あたり GamesPerMatching ゲームに参加するように組み、足りない席はランダムに行動するエージェントにする
+	var games []Game
+	var members []*Participant
+	for r, p := range ranking {
+		if isJoined[p] {
+			members = append(members, p)
+			p.playing = true
+		}
+		if len(members) > 0 && (r == len(ranking)-1 || ranking[r+1].Class != p.Class) {
+			for round := 0; round < GamesPerMatching; round++ {
+				order := append([]*Participant{}, members...)
+				l.rand.Shuffle(len(order), func(a, b int) { order[a], order[b] = order[b], order[a] })
+				for a := 0; a < len(order); a += 3 {
+					g := Game{Class: p.Class}
+					copy(g.Players[:], order[a:])
+					games = append(games, g)
+				}
+			}
+			members = nil
+		}
+	}
+	return games
+}
+
+// ゲームの結果 (Players の順のスコア) から各参加者の順位点を加算する
+func (l *League) Record(g Game, score []int) {
+	points := rating.RankPoints(score)
+	for s, p := range g.Players {
+		if p != nil {
+			p.current += points[s]
+		}
+	}
+}
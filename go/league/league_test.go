@@ -0,0 +1,267 @@
+package league
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// クラス class で points の順 (古い順) に順位点を得た参加者
+func scored(id, class int, points ...float64) *Participant {
+	p := &Participant{Id: id, Class: class}
+	for _, v := range points {
+		p.history = append(p.history, entry{class: class, points: v})
+	}
+	return p
+}
+
+// 参加者をランキングの順に並べた League
+func newLeague(ranking ...*Participant) *League {
+	l := New(rand.New(rand.NewSource(1)))
+	l.Ranking = ranking
+	return l
+}
+
+func ids(ps []*Participant) []int {
+	var result []int
+	for _, p := range ps {
+		result = append(result, p.Id)
+	}
+	return result
+}
+
+func sameIds(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// problem.md のクラス内得点: 直近 n = 2..5 マッチングの順位点合計 x_n について x_n / √n の最大値
+func TestCalcScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		class   int
+		history []entry
+		score   float64
+		ok      bool
+	}{
+		{"x2", 1, []entry{{1, 5}, {1, 5}}, 10 / math.Sqrt(2), true},
+		{"x3", 1, []entry{{1, -8}, {1, -8}, {1, 4}, {1, 4}, {1, 4}}, 12 / math.Sqrt(3), true},
+		{"x4", 1, []entry{{1, -8}, {1, 4}, {1, 4}, {1, 4}, {1, 4}}, 16 / math.Sqrt(4), true},
+		{"x5", 1, []entry{{1, 4}, {1, 4}, {1, 4}, {1, 4}, {1, 4}}, 20 / math.Sqrt(5), true},
+		{"x2 negative", 2, []entry{{2, -2}, {2, -4}}, -6 / math.Sqrt(2), true},
+		// 6 マッチング以上前の順位点は使わない
+		{"last 5", 1, []entry{{1, 8}, {1, 4}, {1, 4}, {1, 4}, {1, 4}, {1, 4}}, 20 / math.Sqrt(5), true},
+		// problem.md の例: 前々回に Class 1 に昇格して +5、前回 Class 1 で +5
+		{"promoted", 1, []entry{{2, 8}, {2, 8}, {1, 5}, {1, 5}}, 10 / math.Sqrt(2), true},
+		{"demoted", 3, []entry{{2, -8}, {3, 2}, {3, 0}, {3, 2}}, 4 / math.Sqrt(3), true},
+		{"N/A one matching", 1, []entry{{1, 8}}, 0, false},
+		{"N/A new", 1, nil, 0, false},
+		{"N/A changed class", 1, []entry{{1, 8}, {2, 8}, {1, 8}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Participant{Class: tt.class, history: tt.history}
+			p.calcScore()
+			if p.HasScore != tt.ok {
+				t.Fatalf("HasScore = %v, want %v", p.HasScore, tt.ok)
+			}
+			if tt.ok && math.Abs(p.Score-tt.score) > 1e-9 {
+				t.Errorf("Score = %v, want %v", p.Score, tt.score)
+			}
+		})
+	}
+}
+
+func TestClassCapacity(t *testing.T) {
+	for n, want := range map[int]int{1: 12, 2: 12, 3: 18, 4: 24, 5: 30} {
+		if got := ClassCapacity(n); got != want {
+			t.Errorf("ClassCapacity(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+// 新規参加者だけの場合はランキングの順に定員でクラスに分ける
+func TestMatchingClasses(t *testing.T) {
+	var joined []*Participant
+	for i := 0; i < 70; i++ {
+		joined = append(joined, &Participant{Id: i})
+	}
+	l := newLeague()
+	l.Matching(joined, false)
+	counts := map[int]int{}
+	for _, p := range l.Ranking {
+		counts[p.Class]++
+	}
+	want := map[int]int{1: 12, 2: 12, 3: 18, 4: 24, 5: 4}
+	for c, n := range want {
+		if counts[c] != n {
+			t.Errorf("class %d: %d participants, want %d", c, counts[c], n)
+		}
+	}
+}
+
+// 新規参加者は joined の順 (マッチング参加APIを実行した順) にランキングの最下位に追加する
+func TestMatchingNewParticipants(t *testing.T) {
+	a, b := scored(1, 1, 2, 2), scored(2, 1, 0, 0)
+	n1, n2, n3 := &Participant{Id: 11}, &Participant{Id: 12}, &Participant{Id: 13}
+	l := newLeague(a, b)
+	l.Matching([]*Participant{n2, a, n1, b, n3}, false)
+	if got, want := ids(l.Ranking), []int{1, 2, 12, 11, 13}; !sameIds(got, want) {
+		t.Errorf("ranking = %v, want %v", got, want)
+	}
+	for _, p := range []*Participant{n1, n2, n3} {
+		if p.Class != 1 || p.entered != enteredNew {
+			t.Errorf("participant %d: class %d, entered %d", p.Id, p.Class, p.entered)
+		}
+	}
+}
+
+// Class 1 (12 名), Class 2 (12 名), Class 3 (18 名) の参加者
+// クラス内では id の順にクラス内得点が高い
+func fullClasses() []*Participant {
+	var ranking []*Participant
+	id := 0
+	for class := 1; class <= 3; class++ {
+		for i := 0; i < ClassCapacity(class); i++ {
+			ranking = append(ranking, scored(id, class, 8-float64(i)*0.5, 0))
+			id++
+		}
+	}
+	return ranking
+}
+
+// クラス n からクラス内得点の上位 n-1 名が昇格し、下位 n 名が降格する
+func TestMatchingPromotion(t *testing.T) {
+	ranking := fullClasses()
+	l := newLeague(ranking...)
+	l.Matching(ranking, false)
+
+	promoted, demoted := map[int]int{}, map[int]int{}
+	for _, p := range ranking {
+		if p.promote {
+			promoted[p.history[0].class]++
+		}
+		if p.demote {
+			demoted[p.history[0].class]++
+		}
+	}
+	for n := 1; n <= 3; n++ {
+		if promoted[n] != promotions(n) || demoted[n] != demotions(n) {
+			t.Errorf("class %d: %d promoted, %d demoted, want %d and %d", n, promoted[n], demoted[n], promotions(n), demotions(n))
+		}
+	}
+
+	// 昇格者は上のクラスの最下位、降格者は下のクラスの最上位になる
+	want := map[int]int{
+		// Class 2 の 1 位 (id 12) が Class 1 の最下位
+		11: 12,
+		// Class 1 の最下位 (id 11) が Class 2 の 1 位
+		12: 11,
+		// Class 3 の上位 2 名 (id 24, 25) が Class 2 の下位
+		22: 24, 23: 25,
+		// Class 2 の下位 2 名 (id 22, 23) が Class 3 の上位
+		24: 22, 25: 23,
+	}
+	for r, id := range want {
+		if l.Ranking[r].Id != id {
+			t.Errorf("rank %d: id %d, want %d", r+1, l.Ranking[r].Id, id)
+		}
+	}
+	// Class 4 がないので Class 3 の降格者は Class 3 の最下位に残る
+	for _, p := range l.Ranking[len(l.Ranking)-3:] {
+		if p.Class != 3 || p.Id < 39 {
+			t.Errorf("participant %d: class %d, want one of the bottom 3 of class 3", p.Id, p.Class)
+		}
+	}
+	for _, p := range l.Ranking {
+		if p.Class == 0 {
+			t.Errorf("participant %d is not ranked", p.Id)
+		}
+	}
+}
+
+// クラス内得点が N/A の参加者は昇格・降格しない
+func TestMatchingNoScore(t *testing.T) {
+	ranking := fullClasses()
+	for _, p := range ranking[12:24] {
+		p.history = p.history[1:]
+	}
+	l := newLeague(ranking...)
+	l.Matching(ranking, false)
+	for _, p := range ranking[12:24] {
+		if p.HasScore || p.promote || p.demote {
+			t.Errorf("participant %d: HasScore %v, promote %v, demote %v", p.Id, p.HasScore, p.promote, p.demote)
+		}
+	}
+	// Class 1 の最下位は降格の対象になるが、Class 2 から昇格する人がいないので Class 1 に残る
+	if p := l.Ranking[11]; p.Id != 11 || p.Class != 1 {
+		t.Errorf("rank 12: id %d class %d, want id 11 class 1", p.Id, p.Class)
+	}
+}
+
+// マッチング参加APIを実行していない参加者はランキングから除外する
+func TestMatchingExcludeAbsent(t *testing.T) {
+	ranking := fullClasses()
+	absent := ranking[3]
+	var joined []*Participant
+	for _, p := range ranking {
+		if p != absent {
+			joined = append(joined, p)
+		}
+	}
+	l := newLeague(ranking...)
+	l.Matching(joined, false)
+	if len(l.Ranking) != len(ranking)-1 {
+		t.Errorf("ranking has %d participants, want %d", len(l.Ranking), len(ranking)-1)
+	}
+	if absent.Class != 0 || absent.Matchings() != 0 {
+		t.Errorf("absent participant: class %d, %d matchings", absent.Class, absent.Matchings())
+	}
+}
+
+// 最後のマッチングでは昇格・降格処理とランキング除外処理を行わない
+func TestMatchingLast(t *testing.T) {
+	ranking := fullClasses()
+	before := ids(ranking)
+	l := newLeague(ranking...)
+	l.Matching(ranking[:30], true)
+	if got := ids(l.Ranking); !sameIds(got, before) {
+		t.Errorf("ranking = %v, want %v", got, before)
+	}
+	for _, p := range ranking {
+		if p.promote || p.demote {
+			t.Errorf("participant %d is promoted or demoted", p.Id)
+		}
+	}
+}
+
+// 参加した全員が同じクラスの相手と GamesPerMatching ゲームずつ対戦する
+func TestMatchingGames(t *testing.T) {
+	ranking := fullClasses()
+	l := newLeague(ranking...)
+	games := l.Matching(ranking, false)
+	count := map[*Participant]int{}
+	for _, g := range games {
+		for _, p := range g.Players {
+			if p == nil {
+				continue
+			}
+			count[p]++
+			if p.Class != g.Class {
+				t.Errorf("participant %d of class %d plays in class %d", p.Id, p.Class, g.Class)
+			}
+		}
+	}
+	for _, p := range ranking {
+		if count[p] != GamesPerMatching {
+			t.Errorf("participant %d plays %d games, want %d", p.Id, count[p], GamesPerMatching)
+		}
+	}
+}
@@ -1,115 +1,169 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math/rand"
-	"net/http"
 	"os"
 	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"tenka/api"
+	"tenka/game"
+	"tenka/geometry"
+	"tenka/replay"
 )
 
 var GameServer = "https://gbc2023.tenka1.klab.jp"
 var TOKEN = "YOUR_TOKEN"
 
-const N = 5
-const TOTAL_TURN = 294
-const N_AGENTS = 4
+// リプレイファイルの出力先
+var OutputDir = "output"
+
+// 使用する bot の名前 (-bot で上書きできる)
+var BotName = "v10"
+
+// 探索の設定 (SearchBudget が 0 の場合は探索しない)
+var SearchBudget time.Duration
+var SearchDepth = 3
+var SearchSamples = 4
+
+// 乱数の seed (環境変数 SEED か -seed で指定する、指定しない場合は現在時刻)
+// 同じ seed と同じレスポンスでは同じ移動を選ぶ (探索モードは探索時間によって結果が変わる)
+var Seed = time.Now().UnixNano()
 
-var Dj = []int{+1, 0, -1, 0}
-var Dk = []int{0, +1, 0, -1}
+// ゲームサーバのAPIのクライアント (init で GameServer から作る)
+var apiClient *api.Client
+
+const N_AGENTS = 4
 
 // 初期化処理
 func init() {
-	rand.Seed(time.Now().Unix())
-
+	if os.Getenv("SEED") != "" {
+		s, err := strconv.ParseInt(os.Getenv("SEED"), 10, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		Seed = s
+	}
 	if os.Getenv("GAME_SERVER") != "" {
 		GameServer = os.Getenv("GAME_SERVER")
 	}
 	if os.Getenv("TOKEN") != "" {
 		TOKEN = os.Getenv("TOKEN")
 	}
-}
-
-// ゲームサーバのAPIを叩く
-func callAPI(x string) ([]byte, error) {
-	url := GameServer + x
-	// err != nilの場合 または 5xxエラーの際は100ms空けて5回までリトライする
-	for i := 0; i < 5; i++ {
-		fmt.Println(url)
-		resp, err := http.Get(url)
+	if os.Getenv("BOT") != "" {
+		BotName = os.Getenv("BOT")
+	}
+	if os.Getenv("OUTPUT_DIR") != "" {
+		OutputDir = os.Getenv("OUTPUT_DIR")
+	}
+	if os.Getenv("WEIGHTS") != "" {
+		w, err := LoadWeights(os.Getenv("WEIGHTS"))
 		if err != nil {
-			log.Printf("%v", err.Error())
-			time.Sleep(time.Millisecond * 100)
-			continue
+			log.Fatal(err)
 		}
-		//goland:noinspection GoUnhandledErrorResult
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
-		if resp.StatusCode == 200 {
-			return body, nil
+		weights = w
+	}
+	if os.Getenv("SEARCH_BUDGET") != "" {
+		d, err := time.ParseDuration(os.Getenv("SEARCH_BUDGET"))
+		if err != nil {
+			log.Fatal(err)
 		}
-		if 500 <= resp.StatusCode && resp.StatusCode < 600 {
-			fmt.Println(resp.Status)
-			time.Sleep(time.Millisecond * 100)
-			continue
+		// 1 ターンは 500ms なので通信の時間を残す
+		if d > 400*time.Millisecond {
+			log.Printf("SEARCH_BUDGET is too long: %s", d)
+			d = 400 * time.Millisecond
 		}
-
-		return nil, fmt.Errorf("Api Error status_code:%d", resp.StatusCode)
+		SearchBudget = d
+	}
+	if os.Getenv("SEARCH_DEPTH") != "" {
+		d, err := strconv.Atoi(os.Getenv("SEARCH_DEPTH"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		SearchDepth = d
+	}
+	if os.Getenv("SEARCH_SAMPLES") != "" {
+		n, err := strconv.Atoi(os.Getenv("SEARCH_SAMPLES"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		SearchSamples = n
 	}
-	return nil, fmt.Errorf("Api Error")
-}
 
-// 移動APIのレスポンス用の構造体
-type StartResponse struct {
-	Status string `json:"status"`
-	Start  int64  `json:"start"`
-	GameId int64  `json:"game_id"`
+	apiClient = api.NewClient(GameServer, TOKEN)
+	apiClient.Log = log.Default()
 }
 
 // 指定したmode, delayで練習試合開始APIを呼ぶ
-func callStart(mode, delay int) *StartResponse {
-	res, err := callAPI(fmt.Sprintf("/api/start/%s/%d/%d", TOKEN, mode, delay))
-	if err != nil {
-		log.Fatal(err)
-	}
-	var move StartResponse
-	err = json.Unmarshal(res, &move)
+func callStart(mode, delay int) *api.StartResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), APITimeout)
+	defer cancel()
+	start, err := apiClient.Start(ctx, mode, delay)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return &move
+	return start
 }
 
 // 移動APIのレスポンス用の構造体
+// bot 用のメソッドを定義するために game.MoveResponse を埋め込む
 type MoveResponse struct {
-	Status  string      `json:"status"`
-	Now     int64       `json:"now"`
-	Turn    int         `json:"turn"`
-	Move    []int       `json:"move"`
-	Score   []int       `json:"score"`
-	Field   [][][][]int `json:"field"`
-	Agent   [][]int     `json:"agent"`
-	Special []int       `json:"special"`
+	game.MoveResponse
+
+	// 敵エージェントの行動のモデル (nil の場合は全方向に等確率で移動するとみなす)
+	opponents *OpponentModel
+	// 評価関数の重み (nil の場合は設定ファイルの重み)
+	weights *Weights
+	// 次のターンの移動APIを呼ぶまでの締め切り (ゼロ値の場合は締め切りなし)
+	deadline time.Time
+}
+
+func (m *MoveResponse) Weights() *Weights {
+	if m.weights == nil {
+		return &weights
+	}
+	return m.weights
+}
+
+// 移動を決めるのに使える時間 (limit と締め切りまでの時間の短い方)
+func (m *MoveResponse) TimeLeft(limit time.Duration) time.Duration {
+	if !m.deadline.IsZero() {
+		if left := time.Until(m.deadline); left < limit {
+			return left
+		}
+	}
+	return limit
 }
 
 // dir方向に移動するように移動APIを呼ぶ
-func callMove(gameId int64, dir0, dir5 string) *MoveResponse {
-	res, err := callAPI(fmt.Sprintf("/api/move/%s/%d/%s/%s", TOKEN, gameId, dir0, dir5))
+// timeout はターンが進んでレスポンスが返るまでの締め切り
+func callMove(gameId int64, dir0, dir5 api.Dir, timeout time.Duration) (*MoveResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	res, err := apiClient.Move(ctx, gameId, dir0, dir5)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	var move MoveResponse
-	err = json.Unmarshal(res, &move)
+	return &MoveResponse{MoveResponse: *res}, nil
+}
+
+// 移動APIの {dir} の形式の文字列を api.Dir に変換する
+// 不正な形式の場合は前進にする
+func toDir(dir string) api.Dir {
+	d, err := api.ParseDir(dir)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		return api.Normal(0)
 	}
-	return &move
+	return d
 }
 
 // game_idを取得する
@@ -126,7 +180,7 @@ func getGameId() int64 {
 
 	// start APIを呼び出し練習試合のgame_idを取得する
 	start := callStart(0, 0)
-	if start.Status == "ok" || start.Status == "started" {
+	if start.Status == api.StatusOk || start.Status == api.StatusStarted {
 		return start.GameId
 	}
 
@@ -134,348 +188,14 @@ func getGameId() int64 {
 	return -1
 }
 
-// (i, j, k) を Field の添え字にする
-func FieldIdx(i, j, k int) int {
-	return (i*N+j)*N + k
-}
-
-// Move 用
-func Func1(memberId, pos int) int {
-	i0 := memberId / 3
-	i1 := memberId % 3
-	j0 := pos / 3
-	j1 := pos % 3
-	return ((j0+1)*i1+j1)%3 + (i0+j0)%2*3
-}
-
-type Agent struct {
-	I int
-	J int
-	K int
-	D int
-}
-
-type Cell struct {
-	Owner int
-	Val   int
-}
-
-type GameLogic struct {
-	Field   []*Cell
-	Agents  []*Agent
-	Turn    int
-	Move    []int
-	Score   []int
-	Area    []int
-	Special []int
-}
-
-func (g *GameLogic) GetCell(i, j, k int) *Cell {
-	return g.Field[FieldIdx(i, j, k)]
-}
-
-// moveList に従ってゲームを進行する
-func (g *GameLogic) Progress(memberId int, moveList []int) {
-	if len(moveList)%6 != 0 {
-		log.Fatal("invalid moveList length")
-	}
-	counter := make([]byte, 6*N*N)
-	fis := make([]int, 6)
-	for i := 0; i < len(moveList); i += 6 {
-		// エージェントの移動処理
-		for idx := 0; idx < 6; idx++ {
-			g.Move[idx] = moveList[i+Func1(memberId, idx)]
-			if g.Move[idx] == -1 || g.Move[idx] >= 4 {
-				continue
-			}
-			g.RotateAgent(idx, g.Move[idx])
-			g.MoveForward(idx)
-			ii := g.Agents[idx].I
-			jj := g.Agents[idx].J
-			kk := g.Agents[idx].K
-			fis[idx] = FieldIdx(ii, jj, kk)
-			counter[fis[idx]] |= 1 << idx
-		}
-
-		// フィールドの更新処理 (通常移動)
-		for idx := 0; idx < 6; idx++ {
-			if g.Move[idx] == -1 || g.Move[idx] >= 4 {
-				continue
-			}
-			var ownerId int
-			if idx < 3 {
-				ownerId = idx
-			} else {
-				ownerId = 5 - idx
-			}
-			if g.CheckCounter(counter[fis[idx]], ownerId, idx) || g.Field[fis[idx]].Owner == ownerId {
-				g.Paint(ownerId, fis[idx])
-			}
-		}
-
-		for idx := 0; idx < 6; idx++ {
-			if g.Move[idx] == -1 || g.Move[idx] >= 4 {
-				continue
-			}
-			counter[fis[idx]] = 0
-		}
-
-		// フィールドの更新処理 (特殊移動)
-		specialFis := make(map[int]bool)
-		for idx := 0; idx < 6; idx++ {
-			if g.Move[idx] <= 3 {
-				continue
-			}
-			g.Special[idx] -= 1
-			var ownerId int
-			if idx < 3 {
-				ownerId = idx
-			} else {
-				ownerId = 5 - idx
-			}
-			if g.Move[idx] <= 7 {
-				// 5 マス前進
-				g.RotateAgent(idx, g.Move[idx])
-				for p := 0; p < 5; p++ {
-					g.MoveForward(idx)
-					ii := g.Agents[idx].I
-					jj := g.Agents[idx].J
-					kk := g.Agents[idx].K
-					fi := FieldIdx(ii, jj, kk)
-					specialFis[fi] = true
-					counter[fi] |= 1 << ownerId
-				}
-			} else {
-				// 指定したマスに移動
-				m := g.Move[idx] - 8
-				mi := Func1(ownerId, m/25)
-				mj := m / 5 % 5
-				mk := m % 5
-				{
-					fi := FieldIdx(mi, mj, mk)
-					specialFis[fi] = true
-					counter[fi] |= 1 << ownerId
-				}
-				for d := 0; d < 4; d++ {
-					g.Agents[idx].I = mi
-					g.Agents[idx].J = mj
-					g.Agents[idx].K = mk
-					g.Agents[idx].D = d
-					g.MoveForward(idx)
-					ii := g.Agents[idx].I
-					jj := g.Agents[idx].J
-					kk := g.Agents[idx].K
-					fi := FieldIdx(ii, jj, kk)
-					specialFis[fi] = true
-					counter[fi] |= 1 << ownerId
-				}
-				g.Agents[idx].I = mi
-				g.Agents[idx].J = mj
-				g.Agents[idx].K = mk
-				g.Agents[idx].D = 0
-			}
-		}
-
-		for fi := range specialFis {
-			switch counter[fi] {
-			case 1:
-				g.ForcePaint(0, fi)
-				break
-			case 2:
-				g.ForcePaint(1, fi)
-				break
-			case 4:
-				g.ForcePaint(2, fi)
-				break
-			}
-			counter[fi] = 0
-		}
-
-		// Score 更新
-		if g.Turn >= TOTAL_TURN/2 {
-			g.AddScore()
-		}
-
-		g.Turn += 1
-	}
-}
-
-// ownerId のみが塗ろうとしているかを判定
-func (g *GameLogic) CheckCounter(counter byte, ownerId, idx int) bool {
-	return (counter == 1<<idx) || (counter == ((1 << idx) | (1 << ownerId)))
-}
-
-// Score 更新
-func (g *GameLogic) AddScore() {
-	for i := 0; i < 3; i++ {
-		g.Score[i] += g.Area[i]
-	}
-}
-
-// FieldIdx が fi のマスを ownerId が塗る (通常移動)
-func (g *GameLogic) Paint(ownerId, fi int) {
-	if g.Field[fi].Owner == -1 {
-		// 誰にも塗られていない場合は ownerId で塗る
-		g.Area[ownerId] += 1
-		g.Field[fi].Owner = ownerId
-		g.Field[fi].Val = 2
-	} else if g.Field[fi].Owner == ownerId {
-		// ownerId で塗られている場合は完全に塗られた状態に上書きする
-		g.Field[fi].Val = 2
-	} else if g.Field[fi].Val == 1 {
-		// ownerId 以外で半分塗られた状態の場合は誰にも塗られていない状態にする
-		g.Area[g.Field[fi].Owner] -= 1
-		g.Field[fi].Owner = -1
-		g.Field[fi].Val = 0
-	} else {
-		// ownerId 以外で完全に塗られた状態の場合は半分塗られた状態にする
-		g.Field[fi].Val -= 1
-	}
-}
-
-// FieldIdx が fi のマスを ownerId が塗る (特殊移動)
-func (g *GameLogic) ForcePaint(ownerId, fi int) {
-	if g.Field[fi].Owner != ownerId {
-		g.Area[ownerId] += 1
-		if g.Field[fi].Owner != -1 {
-			g.Area[g.Field[fi].Owner] -= 1
-		}
-	}
-	g.Field[fi].Owner = ownerId
-	g.Field[fi].Val = 2
-}
-
-// idx のエージェントを v 方向に回転させる
-func (g *GameLogic) RotateAgent(idx, v int) {
-	g.Agents[idx].D += v
-	g.Agents[idx].D %= 4
-}
-
-// idx のエージェントを前進させる
-func (g *GameLogic) MoveForward(idx int) {
-	i := g.Agents[idx].I
-	j := g.Agents[idx].J
-	k := g.Agents[idx].K
-	d := g.Agents[idx].D
-	jj := j + Dj[d]
-	kk := k + Dk[d]
-	if jj >= N {
-		g.Agents[idx].I = i/3*3 + (i%3+1)%3 // [1, 2, 0, 4, 5, 3][i];
-		g.Agents[idx].J = k
-		g.Agents[idx].K = N - 1
-		g.Agents[idx].D = 3
-	} else if jj < 0 {
-		g.Agents[idx].I = (1-i/3)*3 + (4-i%3)%3 // [4, 3, 5, 1, 0, 2][i];
-		g.Agents[idx].J = 0
-		g.Agents[idx].K = N - 1 - k
-		g.Agents[idx].D = 0
-	} else if kk >= N {
-		g.Agents[idx].I = i/3*3 + (i%3+2)%3 // [2, 0, 1, 5, 3, 4][i];
-		g.Agents[idx].J = N - 1
-		g.Agents[idx].K = j
-		g.Agents[idx].D = 2
-	} else if kk < 0 {
-		g.Agents[idx].I = (1-i/3)*3 + (3-i%3)%3 // [3, 5, 4, 0, 2, 1][i];
-		g.Agents[idx].J = N - 1 - j
-		g.Agents[idx].K = 0
-		g.Agents[idx].D = 1
-	} else {
-		g.Agents[idx].J = jj
-		g.Agents[idx].K = kk
-	}
-}
-
-func NewGameLogic(move *MoveResponse) *GameLogic {
-	field := make([]*Cell, 6*N*N)
-	agents := make([]*Agent, 6)
-	area := make([]int, 3)
-	for i := 0; i < 6; i++ {
-		for j := 0; j < N; j++ {
-			for k := 0; k < N; k++ {
-				owner := move.Field[i][j][k][0]
-				field[FieldIdx(i, j, k)] = &Cell{
-					Owner: owner,
-					Val:   move.Field[i][j][k][1],
-				}
-				if owner >= 0 {
-					area[owner] += 1
-				}
-			}
-		}
-	}
-	for i := 0; i < 6; i++ {
-		agents[i] = &Agent{
-			I: move.Agent[i][0],
-			J: move.Agent[i][1],
-			K: move.Agent[i][2],
-			D: move.Agent[i][3],
-		}
-	}
-	return &GameLogic{
-		Field:   field,
-		Agents:  agents,
-		Turn:    move.Turn,
-		Move:    append([]int{}, move.Move...),
-		Score:   append([]int{}, move.Score...),
-		Area:    area,
-		Special: append([]int{}, move.Special...),
-	}
-}
-
 type Program struct {
+	strategy Strategy
+	seed     int64
+	rand     *rand.Rand
 }
 
-func NewProgram() *Program {
-	return &Program{}
-}
-
-func MoveRotation(pos []int, rotation int) []int {
-	nextPos := make([]int, 4)
-	for i := 0; i < 4; i++ {
-		nextPos[i] = pos[i]
-	}
-	nextPos[3] += rotation
-	nextPos[3] %= 4
-	MoveForward(nextPos)
-	return nextPos
-}
-
-func MoveForward(pos []int) {
-	i := pos[0]
-	j := pos[1]
-	k := pos[2]
-	d := pos[3]
-	var jj = j + Dj[d]
-	var kk = k + Dk[d]
-	if jj >= N {
-		pos[0] = i/3*3 + (i%3+1)%3 // [1, 2, 0, 4, 5, 3][i]
-		pos[1] = k
-		pos[2] = N - 1
-		pos[3] = 3
-	} else if jj < 0 {
-		pos[0] = (1-i/3)*3 + (4-i%3)%3 // [4, 3, 5, 1, 0, 2][i]
-		pos[1] = 0
-		pos[2] = N - 1 - k
-		pos[3] = 0
-	} else if kk >= N {
-		pos[0] = i/3*3 + (i%3+2)%3 // [2, 0, 1, 5, 3, 4][i]
-		pos[1] = N - 1
-		pos[2] = j
-		pos[3] = 2
-	} else if kk < 0 {
-		pos[0] = (1-i/3)*3 + (3-i%3)%3 // [3, 5, 4, 0, 2, 1][i]
-		pos[1] = N - 1 - j
-		pos[2] = 0
-		pos[3] = 1
-	} else {
-		pos[1] = jj
-		pos[2] = kk
-	}
-}
-
-// エージェントが同じマスにいるかを判定する
-func IsSamePos(a []int, b []int) bool {
-	return a[0] == b[0] && a[1] == b[1] && a[2] == b[2]
+func NewProgram(strategy Strategy, seed int64) *Program {
+	return &Program{strategy: strategy, seed: seed, rand: rand.New(rand.NewSource(seed))}
 }
 
 // エージェントが同じマスにいるかを判定する
@@ -486,73 +206,39 @@ func IsSameState(a []int, b []int) bool {
 func createMap() [][][]int {
 	m := make([][][]int, 6)
 	for i := 0; i < 6; i++ {
-		m[i] = make([][]int, N)
-		for j := 0; j < N; j++ {
-			m[i][j] = make([]int, N)
+		m[i] = make([][]int, game.N)
+		for j := 0; j < game.N; j++ {
+			m[i][j] = make([]int, game.N)
 		}
 	}
 	return m
 }
 
-var agentMap = []int{0, 1, 2, 2, 1, 0}
-
-func Agent2Player(agent int) int {
-	return agentMap[agent]
-}
-
+// pos からの最短距離がちょうど l の敵エージェント (最短で l ターン後に pos に到達する敵エージェント) を返す
+// 最短距離が l 未満で、回り道をして l ターン後に pos にいる可能性がある敵エージェントは含まない
+// 行動のモデルから pos に到達する可能性が低いと判断したエージェントは除く
 func (m *MoveResponse) EnemiesInLength(pos []int, l int) []int {
-	result := make([]int, 0, N_AGENTS)
-	visited := createMap()
-	visited[pos[0]][pos[1]][pos[2]] = -1
-
-	if l == 0 {
-		for agent, agentPos := range m.Agent[1:5] {
-			if IsSamePos(pos, agentPos) {
-				result = append(result, agent+1)
-			}
-		}
-		return result
+	enemies := m.enemiesInLength(pos, l)
+	if m.opponents == nil {
+		return enemies
 	}
-
-	var current [][]int
-	for d := 0; d < 4; d++ {
-		nextPos := MoveRotation(pos, d)
-		visited[nextPos[0]][nextPos[1]][nextPos[2]] = 1
-
-		if l == 1 {
-			for agent, agentPos := range m.Agent[1:5] {
-				if IsSamePos(nextPos, agentPos) {
-					result = append(result, agent+1)
-				}
-			}
+	result := enemies[:0]
+	for _, agent := range enemies {
+		if m.opponents.MayReach(m, agent, pos, l) {
+			result = append(result, agent)
 		}
-		current = append(current, nextPos)
-	}
-	if l == 1 {
-		return result
 	}
-	var next [][]int
+	return result
+}
 
-	for length := 2; length <= l; length++ {
-		for _, pos := range current {
-			for d := 0; d < 4; d++ {
-				nextPos := MoveRotation(pos, d)
-				if visited[nextPos[0]][nextPos[1]][nextPos[2]] == 0 {
-					visited[nextPos[0]][nextPos[1]][nextPos[2]] = length
-					next = append(next, nextPos)
-
-					if length == l {
-						for agent, agentPos := range m.Agent[1:5] {
-							if IsSamePos(nextPos, agentPos) {
-								result = append(result, agent+1)
-							}
-						}
-					}
-				}
-			}
+// pos からの最短距離がちょうど l の敵エージェント
+func (m *MoveResponse) enemiesInLength(pos []int, l int) []int {
+	result := make([]int, 0, N_AGENTS)
+	c := geometry.Index(pos)
+	for agent := 1; agent <= N_AGENTS; agent++ {
+		if geometry.Distance(c, geometry.Index(m.Agent[agent])) == l {
+			result = append(result, agent)
 		}
-		current = next
-		next = nil
 	}
 	return result
 }
@@ -567,8 +253,8 @@ type Length2Prediction struct {
 func CreateLength2Prediction(move *MoveResponse, agent int, pos []int) Length2Prediction {
 	var length2List [][]int
 	for d := 0; d < 4; d++ {
-		length2Pos := MoveRotation(pos, d)
-		if !IsSamePos(length2Pos, move.Agent[agent]) {
+		length2Pos := game.MoveRotation(pos, d)
+		if !game.IsSamePos(length2Pos, move.Agent[agent]) {
 			length2List = append(length2List, length2Pos)
 		}
 	}
@@ -628,16 +314,16 @@ func (p *ShortTermPrediction) LessThan(p2 *ShortTermPrediction) bool {
 func NewShortTermPrediction(move *MoveResponse, pos []int, target int) ShortTermPrediction {
 	enemies0 := move.EnemiesInLength(pos, 0)
 	for i, e := range enemies0 {
-		enemies0[i] = Agent2Player(e)
+		enemies0[i] = game.Agent2Player(e)
 	}
 	enemies1 := move.EnemiesInLength(pos, 1)
 	for i, e := range enemies1 {
-		enemies1[i] = Agent2Player(e)
+		enemies1[i] = game.Agent2Player(e)
 	}
 	enemies2 := move.EnemiesInLength(pos, 2)
 	for i, e := range enemies2 {
-		log.Println("enemy2", e, Agent2Player(e))
-		enemies2[i] = Agent2Player(e)
+		log.Println("enemy2", e, game.Agent2Player(e))
+		enemies2[i] = game.Agent2Player(e)
 	}
 
 	state := move.Field[pos[0]][pos[1]][pos[2]]
@@ -738,44 +424,17 @@ const (
 	Dont
 )
 
-func CalcPotential(move *MoveResponse, target int, lengthMap [][][]int) int {
-	potential := 0
-	for i := 0; i < 6; i++ {
-		for j := 0; j < N; j++ {
-			for k := 0; k < N; k++ {
-				length := lengthMap[i][j][k]
-				if length > 0 {
-					state := move.Field[i][j][k]
-					if state[1] == 0 {
-						potential += (11 - length) * 10
-					} else if state[0] != 0 && state[1] == 1 {
-						potential += (11 - length) * 8
-						if state[0] == target {
-							potential += (11 - length) * 4
-						}
-					} else if state[0] != 0 && state[1] == 2 {
-						potential += (11 - length) * 5
-						if state[0] == target {
-							potential += (11 - length) * 4
-						}
-					}
-				}
-			}
-		}
-	}
-	return potential
-}
-
 type Prediction struct {
 	pos                 []int
 	rotation            int
 	shortTermPrediction ShortTermPrediction
 	length2Prediction   Length2Prediction
-	potential           int
+	potential           float64
+	weights             *Weights
 }
 
 func CreatePrediction(move *MoveResponse, agent int, rotation int, target int, directionMap [][][][]int) Prediction {
-	pos := MoveRotation(move.Agent[agent], rotation)
+	pos := game.MoveRotation(move.Agent[agent], rotation)
 
 	return Prediction{
 		pos:                 pos,
@@ -783,14 +442,18 @@ func CreatePrediction(move *MoveResponse, agent int, rotation int, target int, d
 		shortTermPrediction: NewShortTermPrediction(move, pos, target),
 		length2Prediction:   CreateLength2Prediction(move, agent, pos),
 		potential:           CalcPotential(move, target, directionMap[rotation]),
+		weights:             move.Weights(),
 	}
 }
 
 // left -> straight -> right -> back
 var rotationOrderEmpty = []int{1, 0, 2, 3}
 
+// 優先する移動が小さい
+// priority と比べるしきい値は p の重み (length2_priority, empty_priority) を使う
 func (p *Prediction) LessThan(p2 *Prediction) bool {
-	if p.shortTermPrediction.priority > SelfHalfMayConflict && p2.shortTermPrediction.priority > SelfHalfMayConflict {
+	priority, priority2 := float64(p.shortTermPrediction.priority), float64(p2.shortTermPrediction.priority)
+	if priority > p.weights.Length2Priority && priority2 > p.weights.Length2Priority {
 		if p.length2Prediction.TotalEmpty() > 0 || p2.length2Prediction.TotalEmpty() > 0 {
 			return !p.length2Prediction.LessThan(&p2.length2Prediction)
 		}
@@ -798,10 +461,10 @@ func (p *Prediction) LessThan(p2 *Prediction) bool {
 	if !p.shortTermPrediction.IsSame(&p2.shortTermPrediction) {
 		return p.shortTermPrediction.LessThan(&p2.shortTermPrediction)
 	}
-	if p.shortTermPrediction.priority > EmptyMayConflict && p.length2Prediction.TotalEmpty() > 0 || p2.length2Prediction.TotalEmpty() > 0 {
+	if priority > p.weights.EmptyPriority && p.length2Prediction.TotalEmpty() > 0 || p2.length2Prediction.TotalEmpty() > 0 {
 		return !p.length2Prediction.LessThan(&p2.length2Prediction)
 	}
-	if p.shortTermPrediction.priority <= EmptyMayConflict {
+	if priority <= p.weights.EmptyPriority {
 		return rotationOrderEmpty[p.rotation] < rotationOrderEmpty[p2.rotation]
 	}
 	return p.rotation < p2.rotation
@@ -821,8 +484,8 @@ func (m *MoveResponse) EstimateRanking() []Rank {
 	}
 	leftTurns := 294 - m.Turn
 	for i := 0; i < 6; i++ {
-		for j := 0; j < N; j++ {
-			for k := 0; k < N; k++ {
+		for j := 0; j < game.N; j++ {
+			for k := 0; k < game.N; k++ {
 				if m.Field[i][j][k][0] != -1 {
 					ranking[m.Field[i][j][k][0]].cellPoint++
 				}
@@ -841,6 +504,19 @@ func (m *MoveResponse) EstimateRanking() []Rank {
 	return ranking
 }
 
+// 妨害の対象にするプレイヤーを返す
+// 自プレイヤーが 1 位または 2 位と推定される場合は順位が近い相手、3 位の場合は 2 位のプレイヤーとする
+func (m *MoveResponse) Target() int {
+	ranking := m.EstimateRanking()
+	log.Println("ranking: ", ranking)
+	if ranking[0].player == 0 {
+		return ranking[1].player
+	} else if ranking[1].player == 0 {
+		return ranking[0].player
+	}
+	return ranking[1].player
+}
+
 type SpecialPrediction struct {
 	isStraight  bool
 	pos         []int
@@ -850,6 +526,35 @@ type SpecialPrediction struct {
 	nEnemyFull  int
 	nEmpty      int
 	nSelf       int
+	nContested  int
+	cells       [][]int
+	weights     *Weights
+}
+
+// 特殊取得対象のマスを数える
+func (p *SpecialPrediction) count(move *MoveResponse, pos []int, target int) {
+	p.cells = append(p.cells, append([]int{}, pos...))
+	state := move.Field[pos[0]][pos[1]][pos[2]]
+	if state[0] == -1 {
+		p.nEmpty++
+	} else if state[0] == 0 {
+		p.nSelf++
+	} else if state[0] == target {
+		if state[1] == 1 {
+			p.nTargetHalf++
+		} else if state[1] == 2 {
+			p.nTargetFull++
+		}
+	} else {
+		if state[1] == 2 {
+			p.nEnemyFull++
+		}
+	}
+	// 次のターンに敵に塗り返される可能性がある
+	// 直進の評価は Version 10 から変えないように、瞬間移動の候補だけで数える
+	if !p.isStraight && (len(move.EnemiesInLength(pos, 0)) > 0 || len(move.EnemiesInLength(pos, 1)) > 0) {
+		p.nContested++
+	}
 }
 
 func NewSpecialPredictionStraight(move *MoveResponse, pos []int, direction int, target int) SpecialPrediction {
@@ -857,27 +562,28 @@ func NewSpecialPredictionStraight(move *MoveResponse, pos []int, direction int,
 		isStraight: true,
 		pos:        pos,
 		direction:  direction,
+		weights:    move.Weights(),
 	}
-	pos = MoveRotation(pos, direction)
+	pos = game.MoveRotation(pos, direction)
 
 	for i := 0; i < 5; i++ {
-		state := move.Field[pos[0]][pos[1]][pos[2]]
-		if state[0] == -1 {
-			p.nEmpty++
-		} else if state[0] == 0 {
-			p.nSelf++
-		} else if state[0] == target {
-			if state[1] == 1 {
-				p.nTargetHalf++
-			} else if state[1] == 2 {
-				p.nTargetFull++
-			}
-		} else {
-			if state[1] == 2 {
-				p.nEnemyFull++
-			}
-		}
-		MoveForward(pos)
+		p.count(move, pos, target)
+		game.MoveForward(pos)
+	}
+	return p
+}
+
+// pos ([i, j, k, 0]) に瞬間移動する特殊移動
+// 移動先のマスと隣接する 4 マスが特殊取得対象になる
+func NewSpecialPredictionTeleport(move *MoveResponse, pos []int, target int) SpecialPrediction {
+	p := SpecialPrediction{
+		isStraight: false,
+		pos:        pos,
+		weights:    move.Weights(),
+	}
+	p.count(move, pos, target)
+	for d := 0; d < 4; d++ {
+		p.count(move, game.MoveRotation(pos, d), target)
 	}
 	return p
 }
@@ -886,72 +592,81 @@ func (p *SpecialPrediction) ApiCall() string {
 	if p.isStraight {
 		return strconv.Itoa(p.direction) + "s"
 	} else {
-		return ""
+		return fmt.Sprintf("%d-%d-%d", p.pos[0], p.pos[1], p.pos[2])
+	}
+}
+
+// GameLogic.Progress に渡す移動の値
+func (p *SpecialPrediction) Move() int {
+	if p.isStraight {
+		return 4 + p.direction
 	}
+	return 8 + game.FieldIdx(p.pos[0], p.pos[1], p.pos[2])
 }
 
 func (p *SpecialPrediction) ShouldSkip() bool {
 	return p.nSelf > 0 || p.nEmpty > 1
 }
 
-func (p *SpecialPrediction) Point() int {
-	return p.nTargetFull*4 + p.nTargetHalf*2 + p.nEnemyFull - p.nEmpty
+func (p *SpecialPrediction) Point() float64 {
+	return p.weights.SpecialTargetFull*float64(p.nTargetFull) +
+		p.weights.SpecialTargetHalf*float64(p.nTargetHalf) +
+		p.weights.SpecialEnemyFull*float64(p.nEnemyFull) +
+		p.weights.SpecialEmpty*float64(p.nEmpty) +
+		p.weights.SpecialContested*float64(p.nContested)
+}
+
+// agent の特殊移動のうち最も Point の高いものを返す
+// 直進の 4 方向と 150 マスへの瞬間移動を比較し、使うべきものがない場合は nil を返す
+func BestSpecialPrediction(move *MoveResponse, agent int, target int) *SpecialPrediction {
+	var best *SpecialPrediction
+	update := func(p SpecialPrediction) {
+		if !p.ShouldSkip() && (best == nil || p.Point() > best.Point()) {
+			best = &p
+		}
+	}
+	for d := 0; d < 4; d++ {
+		update(NewSpecialPredictionStraight(move, move.Agent[agent], d, target))
+	}
+	for i := 0; i < 6; i++ {
+		for j := 0; j < game.N; j++ {
+			for k := 0; k < game.N; k++ {
+				update(NewSpecialPredictionTeleport(move, []int{i, j, k, 0}, target))
+			}
+		}
+	}
+	return best
 }
 
 func (move *MoveResponse) CreateDirectionMap(agent int) [][][][]int {
 	return CreateDirectionLengthMap(move.Agent[agent])
 }
 
+// pos から rotation 方向に回転して前進した場合に最短で到達できるマスまでの距離を rotation ごとに返す
+// 最初の移動が rotation 方向の最短経路がないマスは 0、pos は -1
 func CreateDirectionLengthMap(pos []int) [][][][]int {
+	c := geometry.Index(pos)
 	maps := make([][][][]int, 4)
 	for d := 0; d < 4; d++ {
 		maps[d] = createMap()
 		maps[d][pos[0]][pos[1]][pos[2]] = -1
 	}
-
-	current := make([][][]int, 4)
-	for d := 0; d < 4; d++ {
-		nextPos := MoveRotation(pos, d)
-		maps[d][nextPos[0]][nextPos[1]][nextPos[2]] = 1
-		current[d] = append(current[d], nextPos)
-	}
-	next := make([][][]int, 4)
-
-	for length := 2; ; length++ {
-		for dd := 0; dd < 4; dd++ {
-			for _, pos := range current[dd] {
-				for i := 0; i < 4; i++ {
-					nextPos := MoveRotation(pos, i)
-					found := false
-					for _, m := range maps {
-						if m[nextPos[0]][nextPos[1]][nextPos[2]] != 0 {
-							found = true
-							break
-						}
-					}
-					if !found {
-						next[dd] = append(next[dd], nextPos)
-					}
+	for n := 1; n <= geometry.MaxDistance; n++ {
+		for _, b := range geometry.Ring(c, n) {
+			i, j, k := geometry.Coord(b)
+			for d := 0; d < 4; d++ {
+				if geometry.OnShortestPath(c, pos[3], d, b) {
+					maps[d][i][j][k] = n
 				}
 			}
 		}
-		for dd := 0; dd < 4; dd++ {
-			for _, v := range next[dd] {
-				maps[dd][v[0]][v[1]][v[2]] = length
-			}
-		}
-		if len(next[0]) == 0 && len(next[1]) == 0 && len(next[2]) == 0 && len(next[3]) == 0 {
-			break
-		}
-		current = next
-		next = make([][][]int, 4)
 	}
 	return maps
 }
 
 func printMap(m [][][]int) {
 	for i := 0; i < 6; i++ {
-		for j := 0; j < N; j++ {
+		for j := 0; j < game.N; j++ {
 			fmt.Println(m[i][j])
 		}
 		fmt.Println()
@@ -960,38 +675,105 @@ func printMap(m [][][]int) {
 
 func (bot *Program) useRandomSpecial(nextDir string) string {
 	// 50%で直進の必殺技を使用
-	if rand.Intn(2) == 0 {
+	if bot.rand.Intn(2) == 0 {
 		return nextDir + "s"
 	}
 	// 50%でランダムな場所に瞬間移動
-	i := rand.Intn(6)
-	j := rand.Intn(5)
-	k := rand.Intn(5)
+	i := bot.rand.Intn(6)
+	j := bot.rand.Intn(5)
+	k := bot.rand.Intn(5)
 	return fmt.Sprintf("%d-%d-%d", i, j, k)
 }
 
 // BOTのメイン処理
 func (bot *Program) solve() {
 	gameId := getGameId()
-	nextDir0 := strconv.Itoa(rand.Intn(4))
-	nextDir5 := strconv.Itoa(rand.Intn(4))
+	nextDir0 := strconv.Itoa(bot.rand.Intn(4))
+	nextDir5 := strconv.Itoa(bot.rand.Intn(4))
+
+	opponents := NewOpponentModel()
+	var prevMove *MoveResponse
 
-	var fieldLog [][][][][]int
-	var agentLog [][][]int
+	// 各ターンのレスポンスと選んだ移動をリプレイファイルに記録する
+	recorder, err := replay.Create(OutputDir, gameId, TOKEN, bot.seed)
+	if err != nil {
+		log.Printf("replay.Create: %v", err)
+	} else {
+		defer recorder.Close()
+	}
 
+	var clock turnClock
+	var stats moveStats
+	defer func() {
+		log.Println(stats.String())
+		log.Println("compute: ", &stats.compute)
+		log.Println("response delay: ", &stats.delay)
+		for _, line := range apiClient.LatencyReport() {
+			log.Println(line)
+		}
+	}()
+	lastTurn := 0
+	lastOk := time.Now()
+	backoff := time.Duration(0)
 	for {
 		// 移動APIを呼ぶ
-		move := callMove(gameId, nextDir0, nextDir5)
+		// 次のターンに進んでから 1 ターン待ってもレスポンスがなければ再試行する
+		timeout := FirstMoveTimeout
+		if clock.synced {
+			timeout = time.Until(clock.NextTurn(time.Now())) + TurnDuration
+		}
+		move, err := callMove(gameId, toDir(nextDir0), toDir(nextDir5), timeout)
+		received := time.Now()
+		if err != nil {
+			// 一時的な通信障害で bot が終了しないように、ゲームの残り時間の間は再試行する
+			stats.errors++
+			log.Printf("callMove: %v", err)
+			if received.Sub(lastOk) > time.Duration(game.TOTAL_TURN-lastTurn)*TurnDuration+10*time.Second {
+				log.Println("give up: the game must have finished")
+				break
+			}
+			var httpErr *api.HTTPError
+			if errors.As(err, &httpErr) && httpErr.StatusCode < 500 {
+				// 特殊移動が使えないなど移動の指定が不正な可能性があるので通常移動にする
+				nextDir0, nextDir5 = toDir(nextDir0).Normal().String(), toDir(nextDir5).Normal().String()
+			}
+			backoff = nextBackoff(backoff)
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
 		log.Printf("status = %s\n", move.Status)
-		if move.Status == "already_moved" {
+		if move.Status == api.StatusAlreadyMoved {
+			// このターンの移動は登録済みなので、ターンが進むまで待ってから呼び直す
+			stats.alreadyMoved++
+			wait := clock.NextTurn(received).Sub(received)
+			if wait < MinBackoff {
+				wait = MinBackoff
+			}
+			time.Sleep(wait)
 			continue
-		} else if move.Status != "ok" {
+		} else if move.Status != api.StatusOk {
 			break
 		}
 		log.Printf("turn = %d", move.Turn)
 		log.Printf("score = %d %d %d", move.Score[0], move.Score[1], move.Score[2])
 
-		start := time.Now()
+		start := received
+		lastOk = received
+		clock.Observe(move, received)
+		stats.delay.Add(clock.Delay(move, received))
+		stats.Observe(lastTurn, move)
+		lastTurn = move.Turn
+		move.deadline = clock.NextTurn(received).Add(-MoveMargin)
+
+		if prevMove != nil {
+			opponents.Observe(prevMove, move)
+		}
+		move.opponents = opponents
+		prevMove = move
+		if move.Turn%50 == 0 {
+			log.Println("opponents: ", opponents)
+		}
 
 		// // 4方向で移動した場合を全部シミュレーションする
 		// type dirPair struct {
@@ -1001,13 +783,13 @@ func (bot *Program) solve() {
 		// var bestD []dirPair
 		// for d0 := 0; d0 < 4; d0++ {
 		// 	for d5 := 0; d5 < 4; d5++ {
-		// 		m := NewGameLogic(move)
+		// 		m := game.NewGameLogic(&move.MoveResponse)
 		// 		m.Progress(0, []int{d0, -1, -1, -1, -1, d5})
 		// 		// 自身のエージェントで塗られているマス数をカウントする
 		// 		c := 0
 		// 		for i := 0; i < 6; i++ {
-		// 			for j := 0; j < N; j++ {
-		// 				for k := 0; k < N; k++ {
+		// 			for j := 0; j < game.N; j++ {
+		// 				for k := 0; k < game.N; k++ {
 		// 					if m.GetCell(i, j, k).Owner == 0 {
 		// 						c++
 		// 					}
@@ -1036,184 +818,209 @@ func (bot *Program) solve() {
 		// 	nextDir5 = bot.useRandomSpecial(nextDir5)
 		// }
 
-		directionMap0 := move.CreateDirectionMap(0)
-		directionMap5 := move.CreateDirectionMap(5)
+		nextDir0, nextDir5 = bot.strategy.Decide(move)
 
-		ranking := move.EstimateRanking()
-		log.Println("ranking: ", ranking)
-		var target int
-		if ranking[0].player == 0 {
-			target = ranking[1].player
-		} else if ranking[1].player == 0 {
-			target = ranking[0].player
-		} else {
-			target = ranking[1].player
+		log.Println("next dir0: ", nextDir0)
+		log.Println("next dir5: ", nextDir5)
+
+		t := time.Now()
+		elapsed := t.Sub(start)
+		log.Println("turn: ", move.Turn, ", elapsed: ", elapsed)
+		stats.compute.Add(elapsed)
+		if t.After(move.deadline) {
+			stats.late++
+			log.Println("late: ", t.Sub(move.deadline))
 		}
-		log.Println("target: ", target)
 
-		predictions0 := make([]Prediction, 0, 4)
-		// 4方向で移動した場合を全部シミュレーションする
-		for d := 0; d < 4; d++ {
-			predictions0 = append(predictions0, CreatePrediction(move, 0, d, target, directionMap0))
+		if recorder != nil {
+			err := recorder.Write(&replay.Record{
+				Response: &move.MoveResponse,
+				Dir0:     nextDir0,
+				Dir5:     nextDir5,
+			})
+			if err != nil {
+				log.Printf("recorder.Write: %v", err)
+			}
 		}
+	}
+}
 
-		sort.Slice(predictions0, func(i, j int) bool {
-			return predictions0[i].LessThan(&predictions0[j])
-		})
+// Version 10 のヒューリスティックによる bot
+type Version10 struct {
+	rand     *rand.Rand
+	fieldLog [][][][][]int
+	agentLog [][][]int
+}
 
-		log.Println("predictions0: ", predictions0)
+func NewVersion10(r *rand.Rand) *Version10 {
+	return &Version10{rand: r}
+}
 
-		predictions5 := make([]Prediction, 0, 4)
-		// 4方向で移動した場合を全部シミュレーションする
-		for d := 0; d < 4; d++ {
-			predictions5 = append(predictions5, CreatePrediction(move, 5, d, target, directionMap5))
-		}
+func (v *Version10) Decide(move *MoveResponse) (string, string) {
+	start := time.Now()
 
-		sort.Slice(predictions5, func(i, j int) bool {
-			return predictions5[i].LessThan(&predictions5[j])
-		})
+	directionMap0 := move.CreateDirectionMap(0)
+	directionMap5 := move.CreateDirectionMap(5)
 
-		log.Println("predictions5: ", predictions5)
+	target := move.Target()
+	log.Println("target: ", target)
 
-		idx_0 := 0
-		idx_5 := 0
+	predictions0 := make([]Prediction, 0, 4)
+	// 4方向で移動した場合を全部シミュレーションする
+	for d := 0; d < 4; d++ {
+		predictions0 = append(predictions0, CreatePrediction(move, 0, d, target, directionMap0))
+	}
 
-		// pendulum 2
-		if len(agentLog) > 2 {
-			previousPos := agentLog[len(agentLog)-1][0]
-			pos := predictions0[0].pos
-			if IsSamePos(pos, previousPos) && IsSameState(move.Field[pos[0]][pos[1]][pos[2]], fieldLog[len(fieldLog)-2][pos[0]][pos[1]][pos[2]]) {
-				log.Println("pendulum 0 for 2")
-				idx_0 = 1
-			}
+	sort.Slice(predictions0, func(i, j int) bool {
+		return predictions0[i].LessThan(&predictions0[j])
+	})
+
+	log.Println("predictions0: ", predictions0)
+
+	predictions5 := make([]Prediction, 0, 4)
+	// 4方向で移動した場合を全部シミュレーションする
+	for d := 0; d < 4; d++ {
+		predictions5 = append(predictions5, CreatePrediction(move, 5, d, target, directionMap5))
+	}
+
+	sort.Slice(predictions5, func(i, j int) bool {
+		return predictions5[i].LessThan(&predictions5[j])
+	})
+
+	log.Println("predictions5: ", predictions5)
+
+	idx_0 := 0
+	idx_5 := 0
+
+	// pendulum 2
+	if len(v.agentLog) > 2 {
+		previousPos := v.agentLog[len(v.agentLog)-1][0]
+		pos := predictions0[0].pos
+		if game.IsSamePos(pos, previousPos) && IsSameState(move.Field[pos[0]][pos[1]][pos[2]], v.fieldLog[len(v.fieldLog)-2][pos[0]][pos[1]][pos[2]]) {
+			log.Println("pendulum 0 for 2")
+			idx_0 = 1
 		}
-		if len(agentLog) > 2 {
-			previousPos := agentLog[len(agentLog)-1][5]
-			pos := predictions5[0].pos
-			if IsSamePos(pos, previousPos) && IsSameState(move.Field[pos[0]][pos[1]][pos[2]], fieldLog[len(fieldLog)-2][pos[0]][pos[1]][pos[2]]) {
-				log.Println("pendulum 5 for 2")
-				idx_5 = 1
-			}
+	}
+	if len(v.agentLog) > 2 {
+		previousPos := v.agentLog[len(v.agentLog)-1][5]
+		pos := predictions5[0].pos
+		if game.IsSamePos(pos, previousPos) && IsSameState(move.Field[pos[0]][pos[1]][pos[2]], v.fieldLog[len(v.fieldLog)-2][pos[0]][pos[1]][pos[2]]) {
+			log.Println("pendulum 5 for 2")
+			idx_5 = 1
 		}
-		// pendulum 4
-		if len(agentLog) > 4 && idx_0 == 0 {
-			previousPos := agentLog[len(agentLog)-3][0]
-			pos := predictions0[0].pos
-			if IsSamePos(pos, previousPos) && IsSameState(move.Field[pos[0]][pos[1]][pos[2]], fieldLog[len(fieldLog)-4][pos[0]][pos[1]][pos[2]]) {
-				log.Println("pendulum 0 for 4")
-				idx_0 = 1
-			}
+	}
+	// pendulum 4
+	if len(v.agentLog) > 4 && idx_0 == 0 {
+		previousPos := v.agentLog[len(v.agentLog)-3][0]
+		pos := predictions0[0].pos
+		if game.IsSamePos(pos, previousPos) && IsSameState(move.Field[pos[0]][pos[1]][pos[2]], v.fieldLog[len(v.fieldLog)-4][pos[0]][pos[1]][pos[2]]) {
+			log.Println("pendulum 0 for 4")
+			idx_0 = 1
 		}
-		if len(agentLog) > 4 && idx_5 == 0 {
-			previousPos := agentLog[len(agentLog)-3][5]
-			pos := predictions5[0].pos
-			if IsSamePos(pos, previousPos) && IsSameState(move.Field[pos[0]][pos[1]][pos[2]], fieldLog[len(fieldLog)-4][pos[0]][pos[1]][pos[2]]) {
-				log.Println("pendulum 5 for 4")
-				idx_5 = 1
-			}
+	}
+	if len(v.agentLog) > 4 && idx_5 == 0 {
+		previousPos := v.agentLog[len(v.agentLog)-3][5]
+		pos := predictions5[0].pos
+		if game.IsSamePos(pos, previousPos) && IsSameState(move.Field[pos[0]][pos[1]][pos[2]], v.fieldLog[len(v.fieldLog)-4][pos[0]][pos[1]][pos[2]]) {
+			log.Println("pendulum 5 for 4")
+			idx_5 = 1
 		}
+	}
 
-		if idx_0 == 0 {
-			minPotential := predictions0[0].potential
-			minPotentialIdx := 0
-			maxPotential := 0
-			for i, p := range predictions0 {
-				if p.potential <= minPotential {
-					minPotential = p.potential
-					minPotentialIdx = i
-				}
-				if p.potential >= maxPotential {
-					maxPotential = p.potential
-				}
+	if idx_0 == 0 {
+		minPotential := predictions0[0].potential
+		minPotentialIdx := 0
+		maxPotential := 0.0
+		for i, p := range predictions0 {
+			if p.potential <= minPotential {
+				minPotential = p.potential
+				minPotentialIdx = i
 			}
-			if predictions0[0].shortTermPrediction.priority > SelfHalfMayConflict && minPotentialIdx == 0 && maxPotential-minPotential > 700 {
-				log.Println("potential 0")
-				idx_0 = 1
+			if p.potential >= maxPotential {
+				maxPotential = p.potential
 			}
 		}
+		if float64(predictions0[0].shortTermPrediction.priority) > move.Weights().PotentialPriority && minPotentialIdx == 0 && maxPotential-minPotential > move.Weights().PotentialGap {
+			log.Println("potential 0")
+			idx_0 = 1
+		}
+	}
 
-		if idx_5 == 0 {
-			minPotential := predictions5[0].potential
-			minPotentialIdx := 0
-			maxPotential := 0
-			for i, p := range predictions5 {
-				if p.potential <= minPotential {
-					minPotential = p.potential
-					minPotentialIdx = i
-				}
-				if p.potential >= maxPotential {
-					maxPotential = p.potential
-				}
+	if idx_5 == 0 {
+		minPotential := predictions5[0].potential
+		minPotentialIdx := 0
+		maxPotential := 0.0
+		for i, p := range predictions5 {
+			if p.potential <= minPotential {
+				minPotential = p.potential
+				minPotentialIdx = i
 			}
-			if predictions5[0].shortTermPrediction.priority > SelfHalfMayConflict && minPotentialIdx == 0 && maxPotential-minPotential > 700 {
-				log.Println("potential 5")
-				idx_5 = 1
+			if p.potential >= maxPotential {
+				maxPotential = p.potential
 			}
 		}
-
-		if IsSamePos(move.Agent[0], move.Agent[5]) {
-			log.Println("0 and 5 is the same")
-			idx_5 += 1
+		if float64(predictions5[0].shortTermPrediction.priority) > move.Weights().PotentialPriority && minPotentialIdx == 0 && maxPotential-minPotential > move.Weights().PotentialGap {
+			log.Println("potential 5")
+			idx_5 = 1
 		}
+	}
 
-		if IsSamePos(predictions0[idx_0].pos, predictions5[idx_5].pos) {
-			log.Println("prediction is the same pos")
-			idx_0 += 1
-		}
+	// 特殊移動を使う場合、そのエージェントの通常移動は使わない
+	var special0, special5 *SpecialPrediction
+	if move.Turn > 146 {
+		special0, special5 = PlanSpecials(move, target)
+	}
 
-		nextDir0 = strconv.Itoa(predictions0[idx_0].rotation)
-		nextDir5 = strconv.Itoa(predictions5[idx_5].rotation)
-
-		if move.Turn > 146 {
-			specialDone := false
-			if move.Special[0] > 0 {
-				special := NewSpecialPredictionStraight(move, move.Agent[0], 0, target)
-				for d := 1; d < 4; d++ {
-					special2 := NewSpecialPredictionStraight(move, move.Agent[0], d, target)
-					if !special2.ShouldSkip() && special2.Point() > special.Point() {
-						special = special2
-					}
-				}
-				if !special.ShouldSkip() {
-					log.Println("special 0")
-					nextDir0 = special.ApiCall()
-					specialDone = true
-				}
-			}
-			if !specialDone && move.Special[5] > 0 {
-				special := NewSpecialPredictionStraight(move, move.Agent[5], 0, target)
-				for d := 1; d < 4; d++ {
-					special2 := NewSpecialPredictionStraight(move, move.Agent[5], d, target)
-					if !special2.ShouldSkip() && special2.Point() > special.Point() {
-						special = special2
-					}
-				}
-				if !special.ShouldSkip() {
-					log.Println("special 5")
-					nextDir5 = special.ApiCall()
-					specialDone = true
-				}
-			}
-		}
+	idx_0, idx_5 = PlanJoint(game.NewGameLogic(&move.MoveResponse), predictions0, predictions5, idx_0 == 1, idx_5 == 1, special0, special5)
 
-		log.Println("next dir0: ", nextDir0)
-		log.Println("next dir5: ", nextDir5)
+	nextDir0 := strconv.Itoa(predictions0[idx_0].rotation)
+	nextDir5 := strconv.Itoa(predictions5[idx_5].rotation)
 
-		t := time.Now()
-		elapsed := t.Sub(start)
-		log.Println("turn: ", move.Turn, ", elapsed: ", elapsed)
+	if SearchBudget > 0 {
+		// 盤面をシミュレーションして移動を決める
+		searcher := NewSearcher(move.TimeLeft(SearchBudget-time.Since(start)), SearchSamples, rand.New(rand.NewSource(v.rand.Int63())))
+		searcher.UseOpponentModel(move)
+		fallback := [2]int{predictions0[idx_0].rotation, predictions5[idx_5].rotation}
+		best, depth := searcher.Search(game.NewBoard(&move.MoveResponse), SearchDepth, fallback)
+		log.Println("search: ", best, ", depth: ", depth, ", nodes: ", searcher.nodes)
+		nextDir0 = strconv.Itoa(best[0])
+		nextDir5 = strconv.Itoa(best[1])
+	}
 
-		fieldLog = append(fieldLog, move.Field)
-		agentLog = append(agentLog, move.Agent)
+	if special0 != nil {
+		log.Println("special 0", special0.ApiCall())
+		nextDir0 = special0.ApiCall()
+	}
+	if special5 != nil {
+		log.Println("special 5", special5.ApiCall())
+		nextDir5 = special5.ApiCall()
 	}
+
+	v.fieldLog = append(v.fieldLog, move.Field)
+	v.agentLog = append(v.agentLog, move.Agent)
+	return nextDir0, nextDir5
 }
 
 func main() {
-	log.Println("Version 10")
+	if len(os.Args) > 1 && os.Args[1] == "tune" {
+		runTuner(os.Args[2:])
+		return
+	}
+
+	name := flag.String("bot", BotName, "name of the bot ("+strings.Join(StrategyNames(), ", ")+")")
+	seed := flag.Int64("seed", Seed, "random seed")
+	flag.Parse()
+	// 戦略の乱数は Program の乱数と別の系列にする
+	strategy, err := NewStrategy(*name, rand.New(rand.NewSource(*seed^0x5eed)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Version 10, bot: ", *name, ", seed: ", *seed)
 	// maps := CreateDirectionLengthMap([]int{0, 1, 2, 0})
 	// for i := 0; i < 4; i++ {
 	// 	fmt.Println("map ", i, " ---------")
 	// 	printMap(maps[i])
 	// }
-	NewProgram().solve()
+	NewProgram(strategy, *seed).solve()
 }
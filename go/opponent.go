@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+
+	"tenka/game"
+)
+
+// 衝突を予測する際に考慮する敵エージェントの移動の確率の下限
+const MinConflictProbability = 0.1
+
+// 1 エージェント分の観測した行動の統計
+type OpponentStats struct {
+	total    int
+	stand    int
+	dirCount [4]int
+	// 通常移動で塗れるマス (自プレイヤーが完全に塗った状態でないマス) に移動した回数と、ランダムに移動した場合の期待値
+	paintHits     int
+	paintBaseline float64
+	// 最初に特殊移動を使ったターン (使っていない場合は -1)
+	specialTurn int
+}
+
+// 移動APIの move から敵エージェントの行動の傾向を学習する
+type OpponentModel struct {
+	stats [6]OpponentStats
+}
+
+func NewOpponentModel() *OpponentModel {
+	m := &OpponentModel{}
+	for idx := range m.stats {
+		m.stats[idx].specialTurn = -1
+	}
+	return m
+}
+
+// エージェント idx が pos に通常移動した場合に塗れるか
+func canPaint(move *MoveResponse, idx int, pos []int) bool {
+	state := move.Field[pos[0]][pos[1]][pos[2]]
+	return state[0] != game.Agent2Player(idx) || state[1] != 2
+}
+
+// prev の次のターンの盤面 cur の move を観測する
+func (m *OpponentModel) Observe(prev, cur *MoveResponse) {
+	if cur.Turn != prev.Turn+1 {
+		return
+	}
+	for idx := 1; idx < 5; idx++ {
+		s := &m.stats[idx]
+		v := cur.Move[idx]
+		s.total++
+		if v == -1 {
+			s.stand++
+		} else if v < 4 {
+			s.dirCount[v]++
+			for d := 0; d < 4; d++ {
+				if canPaint(prev, idx, game.MoveRotation(prev.Agent[idx], d)) {
+					s.paintBaseline += 0.25
+				}
+			}
+			if canPaint(prev, idx, game.MoveRotation(prev.Agent[idx], v)) {
+				s.paintHits++
+			}
+		} else if s.specialTurn < 0 {
+			s.specialTurn = cur.Turn
+		}
+	}
+}
+
+// エージェント idx の行動の傾向を返す (ログ用、予測には Probabilities と MayUseSpecial を使う)
+// 練習試合の NPC は "stand" (mode 0) か "random" (mode 1) になる
+func (m *OpponentModel) Kind(idx int) string {
+	s := &m.stats[idx]
+	if s.total < 10 {
+		return "unknown"
+	}
+	if s.stand*10 >= s.total*9 {
+		return "stand"
+	}
+	moved := s.total - s.stand
+	if s.dirCount[0]*2 >= moved {
+		return "straight"
+	}
+	if float64(s.paintHits) > s.paintBaseline*1.2+2 {
+		return "greedy"
+	}
+	return "random"
+}
+
+func (m *OpponentModel) String() string {
+	s := ""
+	for idx := 1; idx < 5; idx++ {
+		s += fmt.Sprintf("%d:%s(special=%d) ", idx, m.Kind(idx), m.stats[idx].specialTurn)
+	}
+	return s
+}
+
+// 次のターンにエージェント idx が移動しない確率と、各方向に通常移動する確率を返す
+// 観測した回数にラプラス平滑化を行い、塗れるマスに移動する傾向がある場合はその方向の確率を上げる
+func (m *OpponentModel) Probabilities(move *MoveResponse, idx int) (float64, [4]float64) {
+	s := &m.stats[idx]
+	pStand := (float64(s.stand) + 0.1) / (float64(s.total) + 1)
+	boost := (float64(s.paintHits) + 1) / (s.paintBaseline + 1)
+	var p [4]float64
+	sum := 0.0
+	for d := 0; d < 4; d++ {
+		p[d] = float64(s.dirCount[d]) + 1
+		if canPaint(move, idx, game.MoveRotation(move.Agent[idx], d)) {
+			p[d] *= boost
+		}
+		sum += p[d]
+	}
+	for d := 0; d < 4; d++ {
+		p[d] *= (1 - pStand) / sum
+	}
+	return pStand, p
+}
+
+// 次のターンにエージェント idx が特殊移動を使う可能性があるか
+// 特殊移動が残っていて、いずれかの敵エージェントが既に特殊移動を使っている (特殊移動を使う時期に入っている) 場合
+func (m *OpponentModel) MayUseSpecial(move *MoveResponse, idx int) bool {
+	if move.Special[idx] <= 0 {
+		return false
+	}
+	for i := 1; i < 5; i++ {
+		if m.stats[i].specialTurn >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// エージェント idx が 5 マス前進の特殊移動で pos を塗れるか
+func straightReaches(move *MoveResponse, idx int, pos []int) bool {
+	for d := 0; d < 4; d++ {
+		p := game.MoveRotation(move.Agent[idx], d)
+		for i := 0; i < 5; i++ {
+			if game.IsSamePos(p, pos) {
+				return true
+			}
+			game.MoveForward(p)
+		}
+	}
+	return false
+}
+
+// エージェント idx が l ターン以内に pos に到達する可能性を考慮すべきか
+// 特殊移動を使う可能性がある場合は、次のターンに 5 マス前進の特殊移動で塗るマスも到達する可能性があるとする
+func (m *OpponentModel) MayReach(move *MoveResponse, idx int, pos []int, l int) bool {
+	if l == 0 {
+		return true
+	}
+	pStand, p := m.Probabilities(move, idx)
+	if l == 1 {
+		reach := 0.0
+		for d := 0; d < 4; d++ {
+			if game.IsSamePos(game.MoveRotation(move.Agent[idx], d), pos) {
+				reach += p[d]
+			}
+		}
+		if reach >= MinConflictProbability {
+			return true
+		}
+		return m.MayUseSpecial(move, idx) && straightReaches(move, idx, pos)
+	}
+	return 1-pStand >= MinConflictProbability
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	"tenka/game"
+	"tenka/geometry"
+)
+
+// 2 エージェントの移動の組を決める際のコスト
+// 単位は fieldValue と同じ (マスの状態 1 段階)
+const (
+	// 同じマスに移動する
+	JointCostSamePos = 2.0
+	// 隣接するマスに移動する (次のターン以降に同じマスを取り合う)
+	JointCostAdjacent = 0.5
+	// ヒューリスティックで最善の移動を避けるべきと判断した場合
+	JointCostSkip = 1.5
+	// ヒューリスティックで選んだ移動から predictions の順位が 1 つ下がるごと
+	JointCostRank = 0.5
+)
+
+func containsPos(cells [][]int, pos []int) bool {
+	for _, c := range cells {
+		if game.IsSamePos(c, pos) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAdjacent(a, b []int) bool {
+	return geometry.Distance(geometry.Index(a), geometry.Index(b)) == 1
+}
+
+// 盤面の価値
+// 自プレイヤーのマスの状態 (半分: 1, 完全: 2) の合計から敵プレイヤーのマスの状態の合計を引いたもの
+func fieldValue(g *game.GameLogic) int {
+	v := 0
+	for _, c := range g.Field {
+		if c.Owner == 0 {
+			v += c.Val
+		} else if c.Owner > 0 {
+			v -= c.Val
+		}
+	}
+	return v
+}
+
+// 自エージェント 0, 5 が move0, move5 で移動した 1 ターン後の fieldValue の増分
+// 敵エージェントは移動しないとする
+func jointGain(g *game.GameLogic, move0, move5 int) (int, error) {
+	next := g.Clone()
+	if err := next.Progress(0, []int{move0, -1, -1, -1, -1, move5}); err != nil {
+		return 0, err
+	}
+	return fieldValue(next) - fieldValue(g), nil
+}
+
+// ヒューリスティックの順位によるコスト
+// predictions の i 番目 (skip の場合は 0 番目を避けて 1 番目を選ぶ) からの順位の差に比例させ、
+// 避けるべき最善の移動を選ぶ場合は JointCostSkip とする
+func rankCost(i int, skip bool) float64 {
+	if !skip {
+		return JointCostRank * float64(i)
+	}
+	if i == 0 {
+		return JointCostSkip
+	}
+	return JointCostRank * float64(i-1)
+}
+
+// エージェントが移動して塗るマス (特殊移動を使う場合は特殊移動で塗るマス)
+func paintedCells(p Prediction, special *SpecialPrediction) [][]int {
+	if special != nil {
+		return special.cells
+	}
+	return [][]int{p.pos}
+}
+
+// 塗るマス a, b が同じマスを含む場合は JointCostSamePos、隣接するマスを含む場合は JointCostAdjacent を返す
+func overlapCost(a, b [][]int) float64 {
+	cost := 0.0
+	for _, x := range a {
+		for _, y := range b {
+			if game.IsSamePos(x, y) {
+				return JointCostSamePos
+			}
+			if isAdjacent(x, y) {
+				cost = JointCostAdjacent
+			}
+		}
+	}
+	return cost
+}
+
+// 自エージェント 0, 5 の移動の組を決め、predictions0, predictions5 の添え字を返す
+// 組ごとに 2 エージェントが同時に移動した盤面を GameLogic.Progress でシミュレーションし、
+// 盤面の価値の増分から、同じマスや隣接するマスを塗るコストと、ヒューリスティックの順位によるコスト (rankCost) を引いた値が最大の組を選ぶ
+// 1 ターン後の盤面の価値だけでは先のターンに塗れるマス (potential など) を考えないので、ヒューリスティックの順位を価値に含める
+// special0, special5 が nil でないエージェントは特殊移動を使い、そのエージェントとのコストは特殊移動で塗るマスとの間で考える
+// 値が同じ場合は predictions の順位の合計が小さい組を選ぶ
+func PlanJoint(g *game.GameLogic, predictions0, predictions5 []Prediction, skip0, skip5 bool, special0, special5 *SpecialPrediction) (int, int) {
+	best0, best5 := 0, 0
+	bestValue := math.Inf(-1)
+	for i5, p5 := range predictions5 {
+		for i0, p0 := range predictions0 {
+			move0, move5 := p0.rotation, p5.rotation
+			if special0 != nil {
+				move0 = special0.Move()
+			}
+			if special5 != nil {
+				move5 = special5.Move()
+			}
+			gain, err := jointGain(g, move0, move5)
+			if err != nil {
+				log.Println("PlanJoint: ", err)
+				continue
+			}
+			value := float64(gain) - overlapCost(paintedCells(p0, special0), paintedCells(p5, special5))
+			if special0 == nil {
+				value -= rankCost(i0, skip0)
+			}
+			if special5 == nil {
+				value -= rankCost(i5, skip5)
+			}
+			if value > bestValue || value == bestValue && i0+i5 < best0+best5 {
+				bestValue = value
+				best0, best5 = i0, i5
+			}
+		}
+	}
+	return best0, best5
+}
+
+// 自エージェント 0, 5 の特殊移動を決める (使わないエージェントは nil)
+// 両方のエージェントが使える場合、塗るマスが重ならなければ同じターンに両方使い、重なる場合は Point の高い方のみ使う
+func PlanSpecials(move *MoveResponse, target int) (*SpecialPrediction, *SpecialPrediction) {
+	var special0, special5 *SpecialPrediction
+	if move.Special[0] > 0 {
+		special0 = BestSpecialPrediction(move, 0, target)
+	}
+	if move.Special[5] > 0 {
+		special5 = BestSpecialPrediction(move, 5, target)
+	}
+	if special0 != nil && special5 != nil {
+		for _, c := range special0.cells {
+			if containsPos(special5.cells, c) {
+				if special5.Point() > special0.Point() {
+					special0 = nil
+				} else {
+					special5 = nil
+				}
+				break
+			}
+		}
+	}
+	return special0, special5
+}
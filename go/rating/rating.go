@@ -0,0 +1,136 @@
+// Package rating はゲームの結果から順位点やレーティングを計算する
+package rating
+
+import (
+	"math"
+	"sort"
+)
+
+// 1 位から 3 位の順位点
+var rankPointTable = []float64{2, 0, -2}
+
+// 1 ゲームの各プレイヤーのスコアから順位点を返す
+// 同点の場合は該当する順位の順位点を平均する
+func RankPoints(score []int) []float64 {
+	order := make([]int, len(score))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return score[order[a]] > score[order[b]] })
+
+	points := make([]float64, len(score))
+	for a := 0; a < len(order); {
+		b := a
+		sum := 0.0
+		for b < len(order) && score[order[b]] == score[order[a]] {
+			sum += rankPointTable[b]
+			b++
+		}
+		for c := a; c < b; c++ {
+			points[order[c]] = sum / float64(b-a)
+		}
+		a = b
+	}
+	return points
+}
+
+// 1 ゲームの各プレイヤーの勝利数を返す
+// 1 位が同点の場合は 1 勝を分け合う
+func Wins(score []int) []float64 {
+	best := math.MinInt
+	n := 0
+	for _, s := range score {
+		if s > best {
+			best = s
+			n = 1
+		} else if s == best {
+			n++
+		}
+	}
+	wins := make([]float64, len(score))
+	for i, s := range score {
+		if s == best {
+			wins[i] = 1 / float64(n)
+		}
+	}
+	return wins
+}
+
+// 平均と信頼区間を求めるための標本
+type Sample struct {
+	N     int
+	sum   float64
+	sumSq float64
+}
+
+func (s *Sample) Add(x float64) {
+	s.N++
+	s.sum += x
+	s.sumSq += x * x
+}
+
+func (s *Sample) Mean() float64 {
+	if s.N == 0 {
+		return 0
+	}
+	return s.sum / float64(s.N)
+}
+
+// 平均の 95% 信頼区間の半幅 (正規近似)
+func (s *Sample) CI95() float64 {
+	if s.N < 2 {
+		return math.Inf(1)
+	}
+	mean := s.Mean()
+	variance := (s.sumSq - float64(s.N)*mean*mean) / float64(s.N-1)
+	if variance < 0 {
+		variance = 0
+	}
+	return 1.96 * math.Sqrt(variance/float64(s.N))
+}
+
+const (
+	InitialRating = 1500
+	DefaultEloK   = 16
+)
+
+// 多人数ゲーム用の Elo レーティング
+// 1 ゲームを参加者の全ペアの 1 対 1 の対戦とみなして更新する
+type Elo struct {
+	K       float64
+	Ratings []float64
+}
+
+func NewElo(n int) *Elo {
+	e := &Elo{
+		K:       DefaultEloK,
+		Ratings: make([]float64, n),
+	}
+	for i := range e.Ratings {
+		e.Ratings[i] = InitialRating
+	}
+	return e
+}
+
+// players[s] のスコアが score[s] だったゲームの結果でレーティングを更新する
+func (e *Elo) Update(players []int, score []int) {
+	delta := make([]float64, len(players))
+	for a := range players {
+		for b := range players {
+			if a == b {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (e.Ratings[players[b]]-e.Ratings[players[a]])/400))
+			actual := 0.5
+			if score[a] > score[b] {
+				actual = 1
+			} else if score[a] < score[b] {
+				actual = 0
+			}
+			delta[a] += e.K / float64(len(players)-1) * (actual - expected)
+		}
+	}
+	for a, p := range players {
+		e.Ratings[p] += delta[a]
+	}
+}
@@ -0,0 +1,108 @@
+// replay パッケージはゲームの記録 (リプレイファイル) を読み書きする
+//
+// リプレイファイルは JSON Lines 形式で、1 行目が Header、2 行目以降が各ターンの Record になる
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tenka/game"
+)
+
+const Version = 1
+
+// リプレイファイルの 1 行目
+type Header struct {
+	Version int   `json:"version"`
+	GameId  int64 `json:"game_id"`
+	Created int64 `json:"created"`
+	// bot の乱数の seed (同じ seed で bot を実行するとゲームを再現できる)
+	Seed int64 `json:"seed"`
+}
+
+// 1 ターン分の記録
+// Response を受け取った後に選んだ移動 Dir0, Dir5 を合わせて記録する
+type Record struct {
+	Response *game.MoveResponse `json:"response"`
+	Dir0     string             `json:"dir0"`
+	Dir5     string             `json:"dir5"`
+}
+
+// トークン token のプレイヤーが記録したゲーム gameId のリプレイファイルのパスを返す
+// 同じゲームに参加した複数の bot が同じディレクトリに記録しても上書きしないようにトークンを含める
+func Path(dir string, gameId int64, token string) string {
+	return filepath.Join(dir, fmt.Sprintf("%d-%s.jsonl", gameId, token))
+}
+
+// リプレイファイルに 1 ターンずつ追記する
+type Writer struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+// dir にトークン token のプレイヤーのゲーム gameId のリプレイファイルを作成する
+// seed は記録した bot の乱数の seed
+// 既にファイルがある場合は上書きせずにエラーを返す
+func Create(dir string, gameId int64, token string, seed int64) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(Path(dir, gameId, token), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{f: f, enc: json.NewEncoder(f)}
+	header := Header{
+		Version: Version,
+		GameId:  gameId,
+		Created: time.Now().UnixMilli(),
+		Seed:    seed,
+	}
+	if err := w.enc.Encode(&header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// 1 ターン分の記録を追記する
+func (w *Writer) Write(rec *Record) error {
+	return w.enc.Encode(rec)
+}
+
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
+
+// リプレイファイル全体
+type Replay struct {
+	Header  Header
+	Records []*Record
+}
+
+// リプレイファイルを読み込む
+func Load(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &Replay{}
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&r.Header); err != nil {
+		return nil, fmt.Errorf("%s: header: %w", path, err)
+	}
+	for dec.More() {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w", path, len(r.Records), err)
+		}
+		r.Records = append(r.Records, &rec)
+	}
+	return r, nil
+}
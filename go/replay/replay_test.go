@@ -0,0 +1,84 @@
+package replay
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"tenka/game"
+	"tenka/server"
+)
+
+// サーバで 1 試合を進め、各ターンのレスポンスと選んだ移動を記録したリプレイファイルを作る
+func writeGame(t *testing.T, dir string) string {
+	t.Helper()
+	srv := server.NewServer(server.Options{
+		TurnDuration:      time.Minute,
+		FirstTurnDeadline: time.Minute,
+		Synchronous:       true,
+		Seed:              1,
+	})
+	m := srv.StartMatchWithSeed([3]string{"a"}, server.ModeRandom, 1)
+	w, err := Create(dir, m.Id, "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx := context.Background()
+	res := m.State(0)
+	for turn := 0; res.Turn < game.TOTAL_TURN; turn++ {
+		dir0, dir5 := strconv.Itoa(turn%4), strconv.Itoa((turn/4)%4)
+		if err := w.Write(&Record{Response: res, Dir0: dir0, Dir5: dir5}); err != nil {
+			t.Fatal(err)
+		}
+		if res, err = m.Move(ctx, 0, dir0, dir5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(&Record{Response: res}); err != nil {
+		t.Fatal(err)
+	}
+	return Path(dir, m.Id, "a")
+}
+
+func TestRoundTrip(t *testing.T) {
+	path := writeGame(t, t.TempDir())
+	r, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Version != Version || r.Header.Seed != 1 {
+		t.Errorf("header = %+v", r.Header)
+	}
+	if len(r.Records) != game.TOTAL_TURN+1 {
+		t.Fatalf("records = %d, want %d", len(r.Records), game.TOTAL_TURN+1)
+	}
+	divergences, checked := r.Validate()
+	if len(divergences) != 0 {
+		t.Errorf("divergences = %v", divergences)
+	}
+	if checked != game.TOTAL_TURN {
+		t.Errorf("checked = %d, want %d", checked, game.TOTAL_TURN)
+	}
+}
+
+// 同じゲームの同じプレイヤーのリプレイファイルは上書きしない
+func TestCreateExisting(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Create(dir, 10000, "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if w, err := Create(dir, 10000, "a", 2); err == nil {
+		w.Close()
+		t.Error("Create overwrote an existing replay file")
+	}
+	w, err = Create(dir, 10000, "b", 1)
+	if err != nil {
+		t.Fatalf("another player in the same game: %v", err)
+	}
+	w.Close()
+}
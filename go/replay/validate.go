@@ -0,0 +1,80 @@
+package replay
+
+import (
+	"fmt"
+
+	"tenka/game"
+)
+
+// 再シミュレーションした盤面とサーバのレスポンスの差異
+type Divergence struct {
+	Turn   int
+	Detail string
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("turn %d: %s", d.Turn, d.Detail)
+}
+
+// 記録された各ターンの盤面に次のターンの move を適用し、次のターンのレスポンスと比較する
+// 連続しないターン (移動APIの呼び出しが遅れたターン) は比較しない
+func (r *Replay) Validate() ([]Divergence, int) {
+	var divergences []Divergence
+	checked := 0
+	for n := 0; n+1 < len(r.Records); n++ {
+		cur := r.Records[n].Response
+		next := r.Records[n+1].Response
+		if next.Turn != cur.Turn+1 {
+			continue
+		}
+		g := game.NewGameLogic(cur)
+		if err := g.Progress(0, next.Move); err != nil {
+			divergences = append(divergences, Divergence{Turn: next.Turn, Detail: err.Error()})
+			continue
+		}
+		for _, detail := range Compare(g, next) {
+			divergences = append(divergences, Divergence{Turn: next.Turn, Detail: detail})
+		}
+		checked++
+	}
+	return divergences, checked
+}
+
+// GameLogic とレスポンスの盤面、エージェント、スコア、特殊移動の残り回数を比較し、異なる箇所を返す
+func Compare(g *game.GameLogic, res *game.MoveResponse) []string {
+	var diffs []string
+	if g.Turn != res.Turn {
+		diffs = append(diffs, fmt.Sprintf("turn: expected %d, got %d", g.Turn, res.Turn))
+	}
+	for i := 0; i < 6; i++ {
+		for j := 0; j < game.N; j++ {
+			for k := 0; k < game.N; k++ {
+				cell := g.GetCell(i, j, k)
+				state := res.Field[i][j][k]
+				if cell.Owner != state[0] || cell.Val != state[1] {
+					diffs = append(diffs, fmt.Sprintf("field (%d,%d,%d): expected [%d,%d], got %v", i, j, k, cell.Owner, cell.Val, state))
+				}
+			}
+		}
+	}
+	for idx, a := range g.Agents {
+		expected := []int{a.I, a.J, a.K, a.D}
+		for n := range expected {
+			if expected[n] != res.Agent[idx][n] {
+				diffs = append(diffs, fmt.Sprintf("agent %d: expected %v, got %v", idx, expected, res.Agent[idx]))
+				break
+			}
+		}
+	}
+	for p := 0; p < 3; p++ {
+		if g.Score[p] != res.Score[p] {
+			diffs = append(diffs, fmt.Sprintf("score %d: expected %d, got %d", p, g.Score[p], res.Score[p]))
+		}
+	}
+	for idx := 0; idx < 6; idx++ {
+		if g.Special[idx] != res.Special[idx] {
+			diffs = append(diffs, fmt.Sprintf("special %d: expected %d, got %d", idx, g.Special[idx], res.Special[idx]))
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,76 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+
+	"tenka/game"
+)
+
+func loadGame(t *testing.T) *Replay {
+	t.Helper()
+	r, err := Load(writeGame(t, t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// 正しく記録したゲームには差異がない
+func TestValidate(t *testing.T) {
+	r := loadGame(t)
+	for _, rec := range r.Records {
+		if diffs := Compare(game.NewGameLogic(rec.Response), rec.Response); len(diffs) != 0 {
+			t.Fatalf("turn %d: %v", rec.Response.Turn, diffs)
+		}
+	}
+	divergences, checked := r.Validate()
+	if len(divergences) != 0 || checked != len(r.Records)-1 {
+		t.Errorf("divergences = %v, checked = %d", divergences, checked)
+	}
+}
+
+// 連続しないターンは比較しない
+func TestValidateSkippedTurn(t *testing.T) {
+	r := loadGame(t)
+	r.Records = append(r.Records[:30], r.Records[31:]...)
+	divergences, checked := r.Validate()
+	if len(divergences) != 0 || checked != len(r.Records)-2 {
+		t.Errorf("divergences = %v, checked = %d", divergences, checked)
+	}
+}
+
+// 記録を書き換えると、そのターンの差異として検出する
+func TestValidateTampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(r *Record)
+		detail string
+	}{
+		{"field", func(r *Record) { r.Response.Field[2][1][3][1]++ }, "field (2,1,3)"},
+		{"agent", func(r *Record) { r.Response.Agent[4][3] = (r.Response.Agent[4][3] + 1) % 4 }, "agent 4"},
+		{"score", func(r *Record) { r.Response.Score[1] += 10 }, "score 1"},
+		{"special", func(r *Record) { r.Response.Special[5]-- }, "special 5"},
+		{"move", func(r *Record) { r.Response.Move[0] = 100 }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := loadGame(t)
+			const turn = 50
+			tt.tamper(r.Records[turn])
+			divergences, _ := r.Validate()
+			if len(divergences) == 0 {
+				t.Fatal("tampered record is not detected")
+			}
+			for _, d := range divergences {
+				// 書き換えたレコードは、前のターンからの再シミュレーションと次のターンの初期盤面の両方で食い違う
+				if d.Turn != turn && d.Turn != turn+1 {
+					t.Errorf("unexpected divergence: %s", d)
+				}
+			}
+			if !strings.Contains(divergences[0].Detail, tt.detail) {
+				t.Errorf("divergence = %s, want %q", divergences[0], tt.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"tenka/game"
+)
+
+// game.Board で盤面をシミュレーションして移動を決める探索
+// Board は代入で複製でき Progress がメモリを確保しないので、GameLogic より多くのノードを調べられる
+// 自エージェント (0, 5) の通常移動 (0-3) の組を全通り試し、敵エージェントの移動はモデルからサンプリングする
+// 特殊移動は探索せず、使う場合は PlanSpecials で決めた特殊移動が探索結果より優先される
+type Searcher struct {
+	deadline time.Time
+	samples  int
+	rand     *rand.Rand
+	// scenarios[depth][n] は深さ depth の n 番目のサンプルの敵エージェントの移動
+	scenarios [][][6]int
+	aborted   bool
+	nodes     int
+	// 敵エージェントの行動のモデルから求めた、移動しない確率と各方向に移動する確率
+	opponents [][5]float64
+}
+
+func NewSearcher(budget time.Duration, samples int, r *rand.Rand) *Searcher {
+	return &Searcher{
+		deadline: time.Now().Add(budget),
+		samples:  samples,
+		rand:     r,
+	}
+}
+
+// 敵エージェントの移動を move の時点の行動のモデルに従ってサンプリングするようにする
+func (s *Searcher) UseOpponentModel(move *MoveResponse) {
+	s.opponents = make([][5]float64, 6)
+	for idx := 1; idx < 5; idx++ {
+		pStand, p := move.opponents.Probabilities(move, idx)
+		s.opponents[idx] = [5]float64{pStand, p[0], p[1], p[2], p[3]}
+	}
+}
+
+// 敵エージェントの移動のモデル
+// UseOpponentModel を呼んでいない場合、前のターンに移動しなかったエージェントは移動しないとし、それ以外は 4 方向に等確率で移動するとする
+func (s *Searcher) sampleEnemyMoves(b *game.Board) [6]int {
+	var moves [6]int
+	for idx := 1; idx < 5; idx++ {
+		if s.opponents != nil {
+			x := s.rand.Float64()
+			moves[idx] = 3
+			for v, p := range s.opponents[idx] {
+				if x < p {
+					moves[idx] = v - 1
+					break
+				}
+				x -= p
+			}
+		} else if b.Move[idx] == -1 {
+			moves[idx] = -1
+		} else {
+			moves[idx] = s.rand.Intn(4)
+		}
+	}
+	return moves
+}
+
+// 探索結果を評価する
+// 残りの得点対象ターンで現在の面積が維持されるとして推定した最終スコアを他プレイヤーと比べる
+func EvaluateBoard(b *game.Board) float64 {
+	left := game.TOTAL_TURN - b.Turn
+	if left > game.TOTAL_TURN/2 {
+		left = game.TOTAL_TURN / 2
+	}
+	var est [3]int
+	for p := 0; p < 3; p++ {
+		est[p] = b.Score[p] + b.Area[p]*left
+	}
+	return float64(2*est[0] - est[1] - est[2])
+}
+
+func (s *Searcher) timeout() bool {
+	if s.aborted {
+		return true
+	}
+	s.nodes++
+	if s.nodes%64 == 0 && time.Now().After(s.deadline) {
+		s.aborted = true
+	}
+	return s.aborted
+}
+
+func (s *Searcher) maxNode(b *game.Board, depth int) float64 {
+	if depth == 0 || b.Turn >= game.TOTAL_TURN {
+		return EvaluateBoard(b)
+	}
+	best := math.Inf(-1)
+	for d0 := 0; d0 < 4; d0++ {
+		for d5 := 0; d5 < 4; d5++ {
+			v := s.chanceNode(b, d0, d5, depth)
+			if s.aborted {
+				return best
+			}
+			best = math.Max(best, v)
+		}
+	}
+	return best
+}
+
+// 無効な移動を含むサンプルは除いて平均する (全てのサンプルが無効な場合は -Inf)
+func (s *Searcher) chanceNode(b *game.Board, d0, d5 int, depth int) float64 {
+	sum := 0.0
+	n := 0
+	for _, enemies := range s.scenarios[depth] {
+		if s.timeout() {
+			return 0
+		}
+		moves := enemies
+		moves[0] = d0
+		moves[5] = d5
+		next := *b
+		if err := next.Progress(0, moves); err != nil {
+			continue
+		}
+		sum += s.maxNode(&next, depth-1)
+		n++
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return sum / float64(n)
+}
+
+// 深さ maxDepth までの期待値最大化探索で自エージェント 0, 5 の移動方向を決める
+// 時間切れになるまで反復深化し、最後に探索し終えた深さの結果を返す
+// 評価値が同じ場合は fallback (ヒューリスティックによる移動) を優先する
+func (s *Searcher) Search(b *game.Board, maxDepth int, fallback [2]int) ([2]int, int) {
+	result := fallback
+	completed := 0
+	for depth := 1; depth <= maxDepth; depth++ {
+		// 移動の候補間で同じ敵の移動を使って比較する
+		s.scenarios = make([][][6]int, depth+1)
+		for d := 1; d <= depth; d++ {
+			for n := 0; n < s.samples; n++ {
+				s.scenarios[d] = append(s.scenarios[d], s.sampleEnemyMoves(b))
+			}
+		}
+
+		best := s.chanceNode(b, fallback[0], fallback[1], depth)
+		bestMove := fallback
+		for d0 := 0; d0 < 4 && !s.aborted; d0++ {
+			for d5 := 0; d5 < 4; d5++ {
+				v := s.chanceNode(b, d0, d5, depth)
+				if s.aborted {
+					break
+				}
+				if v > best {
+					best = v
+					bestMove = [2]int{d0, d5}
+				}
+			}
+		}
+		if s.aborted {
+			break
+		}
+		result = bestMove
+		completed = depth
+	}
+	return result, completed
+}
@@ -0,0 +1,506 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tenka/api"
+	"tenka/game"
+)
+
+const (
+	// 練習試合のモード
+	ModeStandStill = api.ModeStandStill // 他のagentは移動しない
+	ModeRandom     = api.ModeRandom     // 他のagentはランダムに移動する
+
+	FirstGameId = 10000
+)
+
+// 移動APIの {dir} を Progress に渡す移動の値に変換する
+// 0-3: 通常移動, 4-7: 5マス前進 (特殊移動), 8 以上: 指定したマスに移動 (特殊移動)
+func ParseDir(dir string) (int, error) {
+	d, err := api.ParseDir(dir)
+	return int(d), err
+}
+
+// 1 ターン分の進行結果
+type turnResult struct {
+	done chan struct{}
+	res  [3]*game.MoveResponse
+}
+
+func newTurnResult() *turnResult {
+	return &turnResult{done: make(chan struct{})}
+}
+
+// 1 試合分の状態
+// 盤面はプレイヤー 0 から見た座標系 (絶対座標系) で保持し、レスポンス時に各プレイヤーから見た座標系に変換する
+type Match struct {
+	Id    int64
+	Start time.Time
+	// NPC の移動に使う乱数の seed
+	Seed int64
+
+	practice bool
+	opts     Options
+	mu       sync.Mutex
+	tokens   [3]string // 空文字列は NPC
+	npcMode  int
+	rand     *rand.Rand
+	logic    *game.GameLogic
+	moves    []int
+	moved    [3]bool
+	absent   [3]bool
+	allMoved chan struct{}
+	pending  *turnResult
+	finished bool
+	done     chan struct{}
+}
+
+func newMatch(id int64, start time.Time, tokens [3]string, npcMode int, seed int64, opts Options) *Match {
+	m := &Match{
+		Id:       id,
+		Start:    start,
+		Seed:     seed,
+		opts:     opts,
+		tokens:   tokens,
+		npcMode:  npcMode,
+		rand:     rand.New(rand.NewSource(seed)),
+		logic:    game.NewInitialGameLogic(),
+		moves:    []int{-1, -1, -1, -1, -1, -1},
+		allMoved: make(chan struct{}, 1),
+		pending:  newTurnResult(),
+		done:     make(chan struct{}),
+	}
+	m.notifyIfAllMoved()
+	return m
+}
+
+// token のプレイヤー番号を返す
+func (m *Match) Player(token string) int {
+	for p, t := range m.tokens {
+		if t != "" && t == token {
+			return p
+		}
+	}
+	return -1
+}
+
+func (m *Match) Finished() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.finished
+}
+
+// ゲーム終了時に close されるチャネルを返す
+func (m *Match) Done() <-chan struct{} {
+	return m.done
+}
+
+// 絶対座標系での各プレイヤーのスコアを返す
+func (m *Match) Score() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]int{}, m.logic.Score...)
+}
+
+// プレイヤー p から見た現在の盤面を返す
+func (m *Match) State(p int) *game.MoveResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.response(p, time.Now())
+}
+
+// プレイヤー p が以降の移動を行わないことを通知する
+// Synchronous の場合、p の移動を待たずにターンを進めるようになる
+func (m *Match) Leave(p int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.absent[p] = true
+	m.notifyIfAllMoved()
+}
+
+// 参加している全プレイヤーの移動が揃っていれば通知する (m.mu をロックした状態で呼ぶ)
+func (m *Match) notifyIfAllMoved() {
+	for p, token := range m.tokens {
+		if token != "" && !m.moved[p] && !m.absent[p] {
+			return
+		}
+	}
+	select {
+	case m.allMoved <- struct{}{}:
+	default:
+	}
+}
+
+// プレイヤー p から見たレスポンスを作る
+func (m *Match) response(p int, now time.Time) *game.MoveResponse {
+	res := game.Perspective(p).RelLogic(m.logic).Response()
+	res.Now = now.UnixMilli()
+	return res
+}
+
+// 1 ターン進める
+func (m *Match) step() {
+	m.mu.Lock()
+	for p, token := range m.tokens {
+		if token != "" || m.npcMode != ModeRandom {
+			continue
+		}
+		for _, idx := range []int{0, 5} {
+			m.moves[game.Perspective(p).AbsAgent(idx)] = m.rand.Intn(4)
+		}
+	}
+	if err := m.logic.Progress(0, m.moves); err != nil {
+		// Move で検証しているので起こらないはず
+		log.Printf("game %d: %v", m.Id, err)
+	}
+	for i := range m.moves {
+		m.moves[i] = -1
+	}
+	m.moved = [3]bool{}
+	select {
+	case <-m.allMoved:
+	default:
+	}
+	// NPC と退出したプレイヤーだけの場合は移動を待たずに次のターンに進める
+	m.notifyIfAllMoved()
+
+	now := time.Now()
+	t := m.pending
+	for p := 0; p < 3; p++ {
+		t.res[p] = m.response(p, now)
+	}
+	m.pending = newTurnResult()
+	if m.logic.Turn >= game.TOTAL_TURN {
+		m.finished = true
+	}
+	m.mu.Unlock()
+
+	close(t.done)
+}
+
+// ゲーム終了までターンを進める
+// Synchronous でなければ TurnDuration ごとに、Synchronous の場合は全プレイヤーの移動が揃うか締め切りを過ぎた時点でターンを進める
+func (m *Match) run() {
+	time.Sleep(time.Until(m.Start))
+	for turn := 0; turn < game.TOTAL_TURN; turn++ {
+		if m.opts.Synchronous {
+			deadline := m.opts.TurnDuration
+			if turn == 0 {
+				deadline = m.opts.FirstTurnDeadline
+			}
+			timer := time.NewTimer(deadline)
+			select {
+			case <-m.allMoved:
+			case <-timer.C:
+			}
+			timer.Stop()
+		} else {
+			time.Sleep(time.Until(m.Start.Add(time.Duration(turn+1) * m.opts.TurnDuration)))
+		}
+		m.step()
+	}
+	m.mu.Lock()
+	log.Printf("game %d finished: score = %v", m.Id, m.logic.Score)
+	m.mu.Unlock()
+	close(m.done)
+}
+
+// プレイヤー p の移動を登録し、ターンが進むまで待ってレスポンスを返す
+func (m *Match) Move(ctx context.Context, p int, dir0, dir5 string) (*game.MoveResponse, error) {
+	v0, err := ParseDir(dir0)
+	if err != nil {
+		return nil, err
+	}
+	v5, err := ParseDir(dir5)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.finished {
+		m.mu.Unlock()
+		return &game.MoveResponse{Status: api.StatusGameFinished}, nil
+	}
+	if m.moved[p] {
+		m.mu.Unlock()
+		return &game.MoveResponse{Status: api.StatusAlreadyMoved}, nil
+	}
+	idx0 := game.Perspective(p).AbsAgent(0)
+	idx5 := game.Perspective(p).AbsAgent(5)
+	if (v0 >= 4 && m.logic.Special[idx0] <= 0) || (v5 >= 4 && m.logic.Special[idx5] <= 0) {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("special move is already used: %s %s", dir0, dir5)
+	}
+	m.moves[idx0] = v0
+	m.moves[idx5] = v5
+	m.moved[p] = true
+	m.notifyIfAllMoved()
+	t := m.pending
+	m.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return t.res[p], nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// プレイヤー p の移動を登録せずに、ターンが進むまで待ってレスポンスを返す
+func (m *Match) Wait(ctx context.Context, p int) (*game.MoveResponse, error) {
+	m.mu.Lock()
+	if m.finished {
+		m.mu.Unlock()
+		return &game.MoveResponse{Status: api.StatusGameFinished}, nil
+	}
+	t := m.pending
+	m.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return t.res[p], nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type Options struct {
+	// 1 ターンの時間 (Synchronous の場合は 1 ターンの締め切り)
+	TurnDuration time.Duration
+	// マッチングの間隔
+	MatchInterval time.Duration
+	// マッチングしてからゲームが開始するまでの時間
+	StartDelay time.Duration
+	// true の場合、参加している全プレイヤーの移動が揃った時点でターンを進める
+	Synchronous bool
+	// Synchronous の場合の最初のターンの締め切り (bot の起動を待つ)
+	FirstTurnDeadline time.Duration
+	// マッチングと各試合の seed の決定に使う乱数の seed (0 の場合は現在時刻)
+	Seed int64
+}
+
+// オフラインで動作するゲームサーバ
+type Server struct {
+	opts Options
+
+	mu         sync.Mutex
+	rand       *rand.Rand
+	nextGameId int64
+	matches    map[int64]*Match
+	practices  map[string]*Match
+	lastJoin   map[string]time.Time
+	waiting    map[string]bool
+}
+
+func NewServer(opts Options) *Server {
+	if opts.TurnDuration == 0 {
+		opts.TurnDuration = 500 * time.Millisecond
+	}
+	if opts.MatchInterval == 0 {
+		opts.MatchInterval = 150 * time.Second
+	}
+	if opts.FirstTurnDeadline == 0 {
+		opts.FirstTurnDeadline = 10 * time.Second
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	return &Server{
+		opts:       opts,
+		rand:       rand.New(rand.NewSource(opts.Seed)),
+		nextGameId: FirstGameId,
+		matches:    map[int64]*Match{},
+		practices:  map[string]*Match{},
+		lastJoin:   map[string]time.Time{},
+		waiting:    map[string]bool{},
+	}
+}
+
+// tokens のプレイヤーによる試合を作成して開始する
+// tokens の空文字列のプレイヤーは npcMode に従って行動する NPC になる
+func (s *Server) StartMatch(tokens [3]string, npcMode int) *Match {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.startMatch(time.Now().Add(s.opts.StartDelay), tokens, npcMode, s.rand.Int63())
+}
+
+// StartMatch と同じだが、NPC の移動に使う乱数の seed を指定する
+// 同じ seed と同じプレイヤーの移動では同じ試合になる
+func (s *Server) StartMatchWithSeed(tokens [3]string, npcMode int, seed int64) *Match {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.startMatch(time.Now().Add(s.opts.StartDelay), tokens, npcMode, seed)
+}
+
+// 試合を作成して開始する (s.mu をロックした状態で呼ぶ)
+func (s *Server) startMatch(start time.Time, tokens [3]string, npcMode int, seed int64) *Match {
+	m := newMatch(s.nextGameId, start, tokens, npcMode, seed, s.opts)
+	s.nextGameId += 1
+	s.matches[m.Id] = m
+	go m.run()
+	log.Printf("game %d: start = %s, players = %q, seed = %d", m.Id, start.Format(time.RFC3339Nano), tokens, seed)
+	return m
+}
+
+// join API を実行した参加者で 3 人ずつの試合を作る
+// 参加者が不足した場合はランダムに行動する NPC を追加する
+func (s *Server) matching() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := make([]string, 0, len(s.waiting))
+	for token := range s.waiting {
+		tokens = append(tokens, token)
+	}
+	s.waiting = map[string]bool{}
+	sort.Strings(tokens)
+	s.rand.Shuffle(len(tokens), func(i, j int) {
+		tokens[i], tokens[j] = tokens[j], tokens[i]
+	})
+	start := time.Now().Add(s.opts.StartDelay)
+	for i := 0; i < len(tokens); i += 3 {
+		var players [3]string
+		copy(players[:], tokens[i:])
+		s.startMatch(start, players, ModeRandom, s.rand.Int63())
+	}
+}
+
+// MatchInterval ごとにマッチングを行う
+func (s *Server) MatchLoop() {
+	ticker := time.NewTicker(s.opts.MatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.matching()
+	}
+}
+
+func (s *Server) handleStart(token string, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("invalid path")
+	}
+	mode, err := strconv.Atoi(args[0])
+	if err != nil || (mode != ModeStandStill && mode != ModeRandom) {
+		return nil, fmt.Errorf("invalid mode: %s", args[0])
+	}
+	delay, err := strconv.Atoi(args[1])
+	if err != nil || delay < 0 || delay > 10 {
+		return nil, fmt.Errorf("invalid delay: %s", args[1])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.practices[token]; ok && !m.Finished() {
+		return &api.StartResponse{Status: api.StatusStarted, Start: m.Start.UnixMilli(), GameId: m.Id}, nil
+	}
+	start := time.Now().Add(time.Duration(delay) * time.Second)
+	m := s.startMatch(start, [3]string{token}, mode, s.rand.Int63())
+	m.practice = true
+	s.practices[token] = m
+	return &api.StartResponse{Status: api.StatusOk, Start: m.Start.UnixMilli(), GameId: m.Id}, nil
+}
+
+func (s *Server) handleJoin(token string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if last, ok := s.lastJoin[token]; ok && now.Sub(last) < time.Second {
+		return &api.StatusResponse{Status: api.StatusErrorTimeLimit}, nil
+	}
+	s.lastJoin[token] = now
+	s.waiting[token] = true
+
+	gameIds := make([]int64, 0)
+	for id, m := range s.matches {
+		if m.practice {
+			continue
+		}
+		if m.Player(token) >= 0 && !m.Finished() {
+			gameIds = append(gameIds, id)
+		}
+	}
+	sort.Slice(gameIds, func(i, j int) bool { return gameIds[i] < gameIds[j] })
+	return &api.JoinResponse{Status: api.StatusOk, GameIds: gameIds}, nil
+}
+
+func (s *Server) handleMove(r *http.Request, token string, args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("invalid path")
+	}
+	gameId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid game_id: %s", args[0])
+	}
+	s.mu.Lock()
+	m, ok := s.matches[gameId]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("game not found: %d", gameId)
+	}
+	p := m.Player(token)
+	if p < 0 {
+		return nil, fmt.Errorf("invalid token")
+	}
+	res, err := m.Move(r.Context(), p, args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	if res.Status != api.StatusOk {
+		return &api.StatusResponse{Status: res.Status}, nil
+	}
+	return res, nil
+}
+
+// GET /api/move/{token}/{game_id}/{dir0}/{dir5}
+// GET /api/start/{token}/{mode}/{delay}
+// GET /api/join/{token}
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	args := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+	if !strings.HasPrefix(r.URL.Path, "/api/") || len(args) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var res interface{}
+	var err error
+	switch args[0] {
+	case "move":
+		res, err = s.handleMove(r, args[1], args[2:])
+	case "start":
+		res, err = s.handleStart(args[1], args[2:])
+	case "join":
+		if len(args) != 2 {
+			http.NotFound(w, r)
+			return
+		}
+		res, err = s.handleJoin(args[1])
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tenka/api"
+	"tenka/game"
+)
+
+// 締め切りを長くして、移動が揃った時点でターンが進むことを確かめる
+func newSyncServer() *Server {
+	return NewServer(Options{
+		TurnDuration:      time.Minute,
+		FirstTurnDeadline: time.Minute,
+		Synchronous:       true,
+		Seed:              1,
+	})
+}
+
+func waitDone(t *testing.T, m *Match) {
+	t.Helper()
+	select {
+	case <-m.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("match did not finish without waiting for the turn deadline")
+	}
+}
+
+// NPC だけの試合は締め切りを待たずに進む
+func TestSynchronousNPCOnly(t *testing.T) {
+	m := newSyncServer().StartMatchWithSeed([3]string{}, ModeRandom, 1)
+	waitDone(t, m)
+	if !m.Finished() {
+		t.Error("match is not finished")
+	}
+}
+
+// 退出したプレイヤーと NPC だけになった試合は締め切りを待たずに進む
+func TestSynchronousAfterLeave(t *testing.T) {
+	m := newSyncServer().StartMatchWithSeed([3]string{"a"}, ModeRandom, 1)
+	ctx := context.Background()
+	for turn := 1; turn <= 10; turn++ {
+		res, err := m.Move(ctx, 0, "0", "0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != api.StatusOk || res.Turn != turn {
+			t.Fatalf("status = %s, turn = %d, want ok and %d", res.Status, res.Turn, turn)
+		}
+	}
+	m.Leave(0)
+	waitDone(t, m)
+	if got := m.State(0).Turn; got != game.TOTAL_TURN {
+		t.Errorf("turn = %d, want %d", got, game.TOTAL_TURN)
+	}
+}
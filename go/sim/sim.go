@@ -0,0 +1,266 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tenka/api"
+	"tenka/game"
+	"tenka/server"
+)
+
+// プロセス内で動作する bot
+type Bot interface {
+	// state は自プレイヤーから見た盤面 (最初の呼び出しでは turn 0 の盤面)
+	// 返り値は移動APIの {dir0}, {dir5} と同じ形式
+	Move(state *game.MoveResponse) (dir0, dir5 string)
+}
+
+// ランダムに通常移動する bot
+type RandomBot struct {
+	Rand *rand.Rand
+}
+
+func (b *RandomBot) Move(state *game.MoveResponse) (string, string) {
+	return strconv.Itoa(b.Rand.Intn(4)), strconv.Itoa(b.Rand.Intn(4))
+}
+
+// 対戦に参加するプレイヤー
+// Bot が nil でなければプロセス内で、そうでなければ Command を外部プロセスとして実行する
+// どちらも空の場合はランダムに行動する NPC になる
+type Player struct {
+	Name    string
+	Bot     Bot
+	Command string
+	// 外部プロセスの bot に環境変数 SEED で渡す乱数の seed
+	Seed int64
+}
+
+// bot の指定からプレイヤーを作る
+// "npc" はサーバの NPC、"random" はプロセス内のランダムな bot、それ以外は外部プロセスのコマンドとして扱う
+func NewPlayer(spec string, seed int64) Player {
+	switch spec {
+	case "npc":
+		return Player{Name: spec}
+	case "random":
+		return Player{Name: spec, Bot: &RandomBot{Rand: rand.New(rand.NewSource(seed))}}
+	default:
+		return Player{Name: spec, Command: spec, Seed: seed}
+	}
+}
+
+func (p *Player) isNPC() bool {
+	return p.Bot == nil && p.Command == ""
+}
+
+type Options struct {
+	// 1 ターンの締め切り
+	Deadline time.Duration
+	// 最初のターンの締め切り (外部プロセスの bot の起動を待つ)
+	FirstTurnDeadline time.Duration
+	// ゲーム終了後に外部プロセスの bot の終了を待つ時間
+	ExitTimeout time.Duration
+	// 外部プロセスの bot の出力とリプレイファイルの出力先ディレクトリ
+	// 空の場合は出力を捨て、リプレイファイルは一時ディレクトリに書いて削除する
+	LogDir string
+}
+
+// 1 試合の結果
+type Result struct {
+	GameId int64
+	// NPC の移動に使った乱数の seed
+	Seed int64
+	// Play に渡した players の順の各プレイヤーのスコア
+	Score []int
+}
+
+// 全プレイヤーの移動が揃った時点でターンを進める対戦シミュレータ
+// 外部プロセスの bot は同じ移動APIを使って内部のゲームサーバに接続する
+type Simulator struct {
+	opts Options
+	srv  *server.Server
+	url  string
+
+	mu      sync.Mutex
+	nextId  int
+	httpSrv *http.Server
+}
+
+func NewSimulator(opts Options) (*Simulator, error) {
+	if opts.Deadline == 0 {
+		opts.Deadline = 500 * time.Millisecond
+	}
+	if opts.FirstTurnDeadline == 0 {
+		opts.FirstTurnDeadline = 10 * time.Second
+	}
+	if opts.ExitTimeout == 0 {
+		opts.ExitTimeout = 5 * time.Second
+	}
+	srv := server.NewServer(server.Options{
+		TurnDuration:      opts.Deadline,
+		Synchronous:       true,
+		FirstTurnDeadline: opts.FirstTurnDeadline,
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Simulator{
+		opts:    opts,
+		srv:     srv,
+		url:     "http://" + ln.Addr().String(),
+		httpSrv: &http.Server{Handler: srv},
+	}
+	go func() {
+		_ = s.httpSrv.Serve(ln)
+	}()
+	return s, nil
+}
+
+// 内部のゲームサーバの URL を返す
+func (s *Simulator) URL() string {
+	return s.url
+}
+
+func (s *Simulator) Close() error {
+	return s.httpSrv.Close()
+}
+
+func (s *Simulator) newToken(p int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextId += 1
+	return fmt.Sprintf("sim-%d-%d", s.nextId, p)
+}
+
+// players による 1 試合を実行し、ゲーム終了まで待つ
+// seed は NPC の移動に使う乱数の seed で、bot が決定的であれば同じ seed で同じ試合になる
+func (s *Simulator) Play(ctx context.Context, players [3]Player, seed int64) (*Result, error) {
+	var tokens [3]string
+	for p := range players {
+		if !players[p].isNPC() {
+			tokens[p] = s.newToken(p)
+		}
+	}
+	m := s.srv.StartMatchWithSeed(tokens, server.ModeRandom, seed)
+
+	botCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, 3)
+	var wg sync.WaitGroup
+	for p := range players {
+		if players[p].isNPC() {
+			continue
+		}
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			defer m.Leave(p)
+			if players[p].Bot != nil {
+				errs[p] = runBot(botCtx, m, p, players[p].Bot)
+			} else {
+				errs[p] = s.runCommand(botCtx, m, tokens[p], &players[p])
+			}
+		}(p)
+	}
+
+	select {
+	case <-m.Done():
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(s.opts.ExitTimeout):
+		cancel()
+		<-exited
+	}
+
+	for p, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("player %d (%s): %w", p, players[p].Name, err)
+		}
+	}
+	return &Result{
+		GameId: m.Id,
+		Seed:   seed,
+		Score:  m.Score(),
+	}, nil
+}
+
+// プロセス内の bot でゲーム終了まで移動を続ける
+func runBot(ctx context.Context, m *server.Match, p int, bot Bot) error {
+	state := m.State(p)
+	for {
+		dir0, dir5 := bot.Move(state)
+		res, err := m.Move(ctx, p, dir0, dir5)
+		if err != nil {
+			return err
+		}
+		if res.Status == api.StatusAlreadyMoved {
+			// このターンの移動は登録済みなので、古い盤面で移動を決め直さずにターンが進むのを待つ
+			res, err = m.Wait(ctx, p)
+			if err != nil {
+				return err
+			}
+		}
+		if res.Status == api.StatusGameFinished {
+			return nil
+		}
+		if res.Status == api.StatusOk {
+			state = res
+		}
+	}
+}
+
+// 外部プロセスの bot を実行し、終了まで待つ
+func (s *Simulator) runCommand(ctx context.Context, m *server.Match, token string, player *Player) error {
+	args := strings.Split(player.Command, " ")
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	outputDir := s.opts.LogDir
+	if outputDir == "" {
+		dir, err := os.MkdirTemp("", "tenka-replay")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		outputDir = dir
+	}
+	cmd.Env = append(os.Environ(), fmt.Sprintf("GAME_SERVER=%s", s.url), fmt.Sprintf("TOKEN=%s", token), fmt.Sprintf("GAME_ID=%d", m.Id), fmt.Sprintf("SEED=%d", player.Seed), fmt.Sprintf("OUTPUT_DIR=%s", outputDir))
+
+	var out io.Writer = io.Discard
+	if s.opts.LogDir != "" {
+		f, err := os.Create(filepath.Join(s.opts.LogDir, fmt.Sprintf("%d-%s.txt", m.Id, token)))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		// ゲーム終了後に終了しなかったプロセスは kill しているのでエラーにしない
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"tenka/game"
+	"tenka/rating"
+	"tenka/sim"
+)
+
+// Strategy をシミュレータのプロセス内の bot として動かす
+type simBot struct {
+	strategy  Strategy
+	weights   Weights
+	opponents *OpponentModel
+	prev      *MoveResponse
+}
+
+func newSimBot(strategy Strategy, w Weights) *simBot {
+	return &simBot{
+		strategy:  strategy,
+		weights:   w,
+		opponents: NewOpponentModel(),
+	}
+}
+
+func (b *simBot) Move(state *game.MoveResponse) (string, string) {
+	move := &MoveResponse{MoveResponse: *state, weights: &b.weights}
+	if b.prev != nil {
+		b.opponents.Observe(b.prev, move)
+	}
+	move.opponents = b.opponents
+	b.prev = move
+	return b.strategy.Decide(move)
+}
+
+// 調整の途中経過
+// ファイルに保存し、同じファイルを指定して再開すると続きから同じ結果になる
+type TuneState struct {
+	Seed   int64    `json:"seed"`
+	Params []string `json:"params"`
+	// ステップ幅のスケジュールに使う全体の反復回数 (-iterations)
+	Iterations int     `json:"iterations"`
+	Iteration  int     `json:"iteration"`
+	Current    Weights `json:"current"`
+	Best       Weights `json:"best"`
+	BestScore  float64 `json:"best_score"`
+}
+
+func loadTuneState(path string) (*TuneState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// 途中経過に含まれない重みはデフォルト値にする
+	st := TuneState{Current: DefaultWeights, Best: DefaultWeights}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &st, nil
+}
+
+// 書き込み途中で中断しても壊れないように一時ファイルに書いてから置き換える
+func (st *TuneState) save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type tuner struct {
+	sim       *sim.Simulator
+	baseline  Weights
+	opponents []string
+	parallel  int
+}
+
+// 重み w の v10 と opponents の bot で games 試合を行い、w の bot の平均順位点を返す
+// 同じ seed では同じ試合の組み合わせと乱数になる
+func (t *tuner) evaluate(w Weights, seed int64, games int) (float64, error) {
+	points := make([]float64, games)
+	errs := make([]error, games)
+	sem := make(chan struct{}, t.parallel)
+	var wg sync.WaitGroup
+	for g := 0; g < games; g++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			r := rand.New(rand.NewSource(seed + int64(g)))
+			// 席による有利不利をなくすため調整対象の bot の席を試合ごとに変える
+			seat := g % 3
+			var players [3]sim.Player
+			n := 0
+			for p := 0; p < 3; p++ {
+				botRand := rand.New(rand.NewSource(r.Int63()))
+				if p == seat {
+					players[p] = sim.Player{Name: "candidate", Bot: newSimBot(NewVersion10(botRand), w)}
+					continue
+				}
+				name := t.opponents[(g/3+n)%len(t.opponents)]
+				n++
+				strategy, err := NewStrategy(name, botRand)
+				if err != nil {
+					errs[g] = err
+					return
+				}
+				players[p] = sim.Player{Name: name, Bot: newSimBot(strategy, t.baseline)}
+			}
+			res, err := t.sim.Play(context.Background(), players, r.Int63())
+			if err != nil {
+				errs[g] = fmt.Errorf("game %d: %w", g, err)
+				return
+			}
+			points[g] = rating.RankPoints(res.Score)[seat]
+		}(g)
+	}
+	wg.Wait()
+
+	sum := 0.0
+	for g, p := range points {
+		if errs[g] != nil {
+			return 0, errs[g]
+		}
+		sum += p
+	}
+	return sum / float64(games), nil
+}
+
+// 調整を続けられないエラーを出力して終了する
+// log の出力は対戦中の bot のログを出さないように捨てているので、標準エラー出力に直接書く
+func exitTuner(err error) {
+	fmt.Fprintf(os.Stderr, "tune: %v\n", err)
+	os.Exit(1)
+}
+
+// 重みの変化の単位 (デフォルト値の大きさ、ただし 1 以上)
+func paramScale(name string) float64 {
+	return math.Max(math.Abs(*DefaultWeights.Param(name)), 1)
+}
+
+// 自己対戦で評価関数の重みを調整する (SPSA)
+// 各反復で全ての重みを同時にランダムな方向に ±c 動かした 2 つの重みで同じ試合を行い、順位点の差から勾配を推定する
+//
+//	go run . tune -iterations 200 -games 12 -state tune.json -out weights.json
+func runTuner(args []string) {
+	fs := flag.NewFlagSet("tune", flag.ExitOnError)
+	seed := fs.Int64("seed", 1, "random seed (ignored when resuming)")
+	iterations := fs.Int("iterations", 100, "total number of iterations (must be the same when resuming)")
+	games := fs.Int("games", 12, "number of games for each perturbed weights")
+	evalGames := fs.Int("eval-games", 30, "number of games to evaluate the current weights")
+	evalEvery := fs.Int("eval-every", 5, "evaluate the current weights every n iterations")
+	parallel := fs.Int("parallel", runtime.NumCPU(), "number of games played in parallel")
+	params := fs.String("params", "", "comma separated names of the weights to tune (default all)")
+	opponents := fs.String("opponents", "v10,greedy,sniper", "comma separated names of the opponent bots")
+	a := fs.Float64("a", 0.2, "step size")
+	c := fs.Float64("c", 0.1, "perturbation size")
+	statePath := fs.String("state", "tune-state.json", "file to save and resume the progress")
+	out := fs.String("out", "weights.json", "file to write the best weights")
+	_ = fs.Parse(args)
+
+	if *iterations <= 0 || *games <= 0 || *evalGames <= 0 || *evalEvery <= 0 || *parallel <= 0 {
+		fmt.Fprintln(os.Stderr, "-iterations, -games, -eval-games, -eval-every and -parallel must be positive")
+		os.Exit(2)
+	}
+	for _, name := range strings.Split(*opponents, ",") {
+		if _, err := NewStrategy(name, rand.New(rand.NewSource(0))); err != nil {
+			fmt.Fprintf(os.Stderr, "-opponents: %v (available: %s)\n", err, strings.Join(StrategyNames(), ", "))
+			os.Exit(2)
+		}
+	}
+
+	// 対戦中の bot とシミュレータのログは出さない (調整のエラーは exitTuner で出力する)
+	log.SetOutput(io.Discard)
+	s, err := sim.NewSimulator(sim.Options{
+		// 締め切りによって結果が変わらないように十分長くする
+		Deadline:          10 * time.Second,
+		FirstTurnDeadline: 10 * time.Second,
+	})
+	if err != nil {
+		exitTuner(err)
+	}
+	defer s.Close()
+	t := &tuner{
+		sim:       s,
+		baseline:  weights,
+		opponents: strings.Split(*opponents, ","),
+		parallel:  *parallel,
+	}
+
+	st, err := loadTuneState(*statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		st = &TuneState{
+			Seed:       *seed,
+			Params:     WeightNames(),
+			Iterations: *iterations,
+			Current:    weights,
+			Best:       weights,
+		}
+		if *params != "" {
+			st.Params = strings.Split(*params, ",")
+		}
+		for _, name := range st.Params {
+			if DefaultWeights.Param(name) == nil {
+				fmt.Fprintf(os.Stderr, "unknown weight: %s\n", name)
+				os.Exit(2)
+			}
+		}
+		st.BestScore, err = t.evaluate(st.Best, st.Seed, *evalGames)
+		if err != nil {
+			exitTuner(err)
+		}
+		fmt.Printf("initial: score = %+.3f\n", st.BestScore)
+		if err := st.save(*statePath); err != nil {
+			exitTuner(err)
+		}
+	} else if err != nil {
+		exitTuner(err)
+	} else if st.Iterations != *iterations {
+		// ステップ幅が変わると途中から同じ結果にならない
+		fmt.Fprintf(os.Stderr, "%s was started with -iterations %d, resume with the same value\n", *statePath, st.Iterations)
+		os.Exit(2)
+	} else {
+		fmt.Printf("resume from iteration %d (seed = %d)\n", st.Iteration, st.Seed)
+	}
+
+	for st.Iteration < *iterations {
+		k := st.Iteration
+		r := rand.New(rand.NewSource(st.Seed*1000003 + int64(k)))
+		ck := *c / math.Pow(float64(k+1), 0.101)
+		ak := *a / math.Pow(float64(k+1)+float64(*iterations)/10, 0.602)
+
+		delta := make([]float64, len(st.Params))
+		plus := st.Current
+		minus := st.Current
+		for i, name := range st.Params {
+			delta[i] = float64(r.Intn(2)*2 - 1)
+			*plus.Param(name) += ck * paramScale(name) * delta[i]
+			*minus.Param(name) -= ck * paramScale(name) * delta[i]
+		}
+		// 2 つの重みを同じ試合で比べる
+		gameSeed := r.Int63()
+		fp, err := t.evaluate(plus, gameSeed, *games)
+		if err != nil {
+			exitTuner(err)
+		}
+		fm, err := t.evaluate(minus, gameSeed, *games)
+		if err != nil {
+			exitTuner(err)
+		}
+		for i, name := range st.Params {
+			g := (fp - fm) / (2 * ck * delta[i])
+			*st.Current.Param(name) += ak * paramScale(name) * g
+		}
+		st.Iteration++
+		fmt.Printf("iteration %d: f+ = %+.3f, f- = %+.3f\n", st.Iteration, fp, fm)
+
+		if st.Iteration%*evalEvery == 0 || st.Iteration == *iterations {
+			// 毎回同じ試合で評価して最も良い重みを残す
+			score, err := t.evaluate(st.Current, st.Seed, *evalGames)
+			if err != nil {
+				exitTuner(err)
+			}
+			fmt.Printf("iteration %d: score = %+.3f (best = %+.3f)\n", st.Iteration, score, st.BestScore)
+			if score > st.BestScore {
+				st.Best = st.Current
+				st.BestScore = score
+			}
+		}
+		if err := st.save(*statePath); err != nil {
+			exitTuner(err)
+		}
+	}
+
+	if err := st.Best.Save(*out); err != nil {
+		exitTuner(err)
+	}
+	fmt.Printf("best score = %+.3f: %s\n", st.BestScore, st.Best)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tenka/api"
+	"tenka/game"
+)
+
+// 1 ターンの時間
+const TurnDuration = 500 * time.Millisecond
+
+// 移動APIのリクエストがターンの締め切りに間に合うように残す時間
+const MoveMargin = 50 * time.Millisecond
+
+// 移動API以外のリクエストの締め切り
+const APITimeout = 5 * time.Second
+
+// 練習試合の開始を待つ最初の移動APIの締め切り (start API の delay は最大 10 秒)
+const FirstMoveTimeout = 15 * time.Second
+
+// 通信エラー時の再試行の間隔の範囲
+const (
+	MinBackoff = 50 * time.Millisecond
+	MaxBackoff = time.Second
+)
+
+// レスポンスの now からサーバのターンの進行を推定する
+type turnClock struct {
+	// サーバの時刻 - ローカルの時刻
+	// 通信の遅延があるとローカルで受け取った時刻が遅れて小さくなるので、最大値を使う
+	offset time.Duration
+	synced bool
+	// 最後に受け取ったレスポンスのサーバの時刻
+	last time.Time
+}
+
+// received はレスポンスを受け取ったローカルの時刻
+func (c *turnClock) Observe(move *MoveResponse, received time.Time) {
+	now := time.UnixMilli(move.Now)
+	offset := now.Sub(received)
+	if !c.synced || offset > c.offset {
+		c.offset = offset
+		c.synced = true
+	}
+	c.last = now
+}
+
+// サーバがレスポンスを返してから受け取るまでにかかった時間の、最も速かったレスポンスとの差
+// 移動APIの所要時間はターンが進むまでの待ち時間を含むので、通信の遅れはこちらで見る
+func (c *turnClock) Delay(move *MoveResponse, received time.Time) time.Duration {
+	return c.offset - time.UnixMilli(move.Now).Sub(received)
+}
+
+// 次にターンが進む時刻 (ローカルの時刻)
+// 最後のレスポンスから何ターンか過ぎている場合は t 以降で最初のターンの区切りを返す
+// 一度もレスポンスを受け取っていない場合は t を返す
+func (c *turnClock) NextTurn(t time.Time) time.Time {
+	if !c.synced {
+		return t
+	}
+	next := c.last.Add(-c.offset).Add(TurnDuration)
+	if next.Before(t) {
+		next = next.Add(t.Sub(next).Truncate(TurnDuration) + TurnDuration)
+	}
+	return next
+}
+
+// 次の再試行までの待ち時間
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d < MinBackoff {
+		return MinBackoff
+	}
+	if d > MaxBackoff {
+		return MaxBackoff
+	}
+	return d
+}
+
+// 移動ループの統計
+type moveStats struct {
+	// 自エージェントが移動しなかったターン (move が -1)
+	missed []int
+	// レスポンスを受け取れなかったターンの数
+	skipped int
+	// 締め切りまでに移動を決められなかったターンの数
+	late         int
+	errors       int
+	alreadyMoved int
+	// 移動を決めるのにかかった時間
+	compute api.Histogram
+	// レスポンスの遅れ (turnClock.Delay)
+	delay api.Histogram
+}
+
+// 前回受け取ったターン prevTurn と今回のレスポンスからターンの取りこぼしを記録する
+func (s *moveStats) Observe(prevTurn int, move *MoveResponse) {
+	if move.Turn > prevTurn+1 {
+		s.skipped += move.Turn - prevTurn - 1
+	}
+	if move.Move[0] == -1 || move.Move[5] == -1 {
+		s.missed = append(s.missed, move.Turn)
+	}
+}
+
+func (s *moveStats) String() string {
+	missed := make([]string, len(s.missed))
+	for i, t := range s.missed {
+		missed[i] = fmt.Sprint(t)
+	}
+	return fmt.Sprintf("missed turns: %d/%d [%s], skipped responses: %d, late: %d, errors: %d, already_moved: %d",
+		len(s.missed), game.TOTAL_TURN, strings.Join(missed, " "), s.skipped, s.late, s.errors, s.alreadyMoved)
+}
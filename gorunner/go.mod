@@ -1,9 +1,13 @@
 module gorunner
 
-go 1.16
+go 1.21.1
 
 require (
 	github.com/BurntSushi/toml v1.1.0
 	github.com/pkg/browser v0.0.0-20210911075715-681adbf594b8
-	golang.org/x/sys v0.9.0 // indirect
+	tenka v0.0.0
 )
+
+require golang.org/x/sys v0.9.0 // indirect
+
+replace tenka => ../go
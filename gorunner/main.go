@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	_ "embed"
 	"encoding/json"
@@ -24,6 +25,8 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/browser"
+
+	"tenka/api"
 )
 
 //go:embed index.html
@@ -32,8 +35,11 @@ var indexHtml string
 //go:embed viewLog.html
 var viewLogHtml string
 
+//go:embed visualizer.html
+var visualizerHtml string
+
 const (
-	DefaultGameServer = "https://gbc2023.tenka1.klab.jp"
+	DefaultGameServer = api.DefaultServer
 	MaxOutputFiles    = 50
 	reloadTemplate    = false
 )
@@ -68,6 +74,7 @@ var (
 	logFilePath        string
 	indexTemplate      *template.Template
 	viewLogTemplate    *template.Template
+	visualizerTemplate *template.Template
 	gMtx               sync.Mutex
 	errorMsgQueue      []string
 	commands           []string
@@ -95,6 +102,7 @@ func isExecuteFromBinary() bool {
 func init() {
 	indexTemplate = template.Must(template.New("index.html").Parse(indexHtml))
 	viewLogTemplate = template.Must(template.New("viewLog.html").Parse(viewLogHtml))
+	visualizerTemplate = template.Must(template.New("visualizer.html").Parse(visualizerHtml))
 	errorMsgQueue = make([]string, 0)
 	commands = []string{"", "", "", ""}
 
@@ -171,46 +179,26 @@ func saveConfig() error {
 	return toml.NewEncoder(f).Encode(conf)
 }
 
-type Start struct {
-	Status string `json:"status"`
-	Start  int64  `json:"start"`
-	GameId int64  `json:"game_id"`
-}
-
-type Join struct {
-	Status  string  `json:"status"`
-	GameIds []int64 `json:"game_ids"`
-}
-
-func callAPI(x string) ([]byte, error) {
-	url := conf.GameServer + x
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	//goland:noinspection GoUnhandledErrorResult
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf(resp.Status)
-	}
-	return body, err
+// 現在の設定のゲームサーバとトークンでAPIのクライアントを作る
+// 設定は画面から変更されるので呼び出しごとに作るが、api パッケージのクライアントはトランスポートを共有するので接続は使い回される
+// start / join API は api パッケージでも再試行しない
+func newAPIClient() *api.Client {
+	c := api.NewClient(conf.GameServer, conf.Token)
+	c.Log = log.Default()
+	return c
 }
 
-func callStart(mode, delay int) (*Start, error) {
-	res, err := callAPI(fmt.Sprintf("/api/start/%s/%d/%d", conf.Token, mode, delay))
-	if err != nil {
-		return nil, err
-	}
-	var move Start
-	err = json.Unmarshal(res, &move)
-	return &move, err
+func callStart(mode, delay int) (*api.StartResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return newAPIClient().Start(ctx, mode, delay)
 }
 
-func callJoin() (*Join, error) {
+func callJoin() (*api.JoinResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	start := time.Now()
-	res, err := callAPI(fmt.Sprintf("/api/join/%s", conf.Token))
+	join, err := newAPIClient().Join(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -220,9 +208,7 @@ func callJoin() (*Join, error) {
 	joinApiRTT = rtt
 	gMtx.Unlock()
 
-	var join Join
-	err = json.Unmarshal(res, &join)
-	return &join, err
+	return join, nil
 }
 
 func writeLine(mtx *sync.Mutex, f *os.File, prefix string, line []byte) error {
@@ -255,7 +241,7 @@ func execCommand(gameType string, gameId, name string, arg ...string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, name, arg...)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("GAME_SERVER=%s", conf.GameServer), fmt.Sprintf("TOKEN=%s", conf.Token), fmt.Sprintf("GAME_ID=%s", gameId))
+	cmd.Env = append(os.Environ(), fmt.Sprintf("GAME_SERVER=%s", conf.GameServer), fmt.Sprintf("TOKEN=%s", conf.Token), fmt.Sprintf("GAME_ID=%s", gameId), fmt.Sprintf("OUTPUT_DIR=%s", outputDir))
 	err := func() error {
 		stdoutReader, err := cmd.StdoutPipe()
 		if err != nil {
@@ -494,7 +480,7 @@ func handleStart(w http.ResponseWriter, r *http.Request) {
 			setLastError(fmt.Sprintf("callStart Error: %v", err))
 			return
 		}
-		if start.Status == "ok" || start.Status == "started" {
+		if start.Status == api.StatusOk || start.Status == api.StatusStarted {
 			log.Printf("start.Start: %d", start.Start)
 			log.Printf("start.GameId: %d", start.GameId)
 			err = execCommand("練習", fmt.Sprintf("%d", start.GameId), cmd[0], cmd[1:]...)
@@ -568,6 +554,78 @@ func handleViewLog(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// リプレイファイル取得API
+// リプレイファイル ({gameId}-{token}.jsonl) の from 行目以降の書き込みが完了した行と、bot が実行中かどうかを返す
+func handleReadReplay(w http.ResponseWriter, r *http.Request) {
+	gameId, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+
+	gMtx.Lock()
+	running := false
+	for _, p := range executingProcesses {
+		if p.GameId == gameId {
+			running = true
+		}
+	}
+	gMtx.Unlock()
+
+	lines := []json.RawMessage{}
+	errorMsg := ""
+	// 試合後にトークンを変更していても読めるように、トークンの部分はファイル名から探す
+	var data []byte
+	paths, err := filepath.Glob(filepath.Join(outputDir, fmt.Sprintf("%d-*.jsonl", gameId)))
+	if err == nil && len(paths) == 0 {
+		err = fmt.Errorf("replay file of game %d not found", gameId)
+	}
+	if err == nil {
+		data, err = os.ReadFile(paths[0])
+	}
+	if err != nil {
+		errorMsg = fmt.Sprintf("read replay error: %s", err)
+	} else {
+		// 最後の行は書き込み途中の可能性があるので改行で終わっている行だけを返す
+		for n := 0; ; n++ {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			if n >= from {
+				lines = append(lines, json.RawMessage(data[:i]))
+			}
+			data = data[i+1:]
+		}
+	}
+
+	res, err := json.Marshal(map[string]interface{}{
+		"lines":   lines,
+		"running": running,
+		"error":   errorMsg,
+	})
+	if err != nil {
+		setLastError(fmt.Sprintf("json.Marshal Error: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, string(res))
+}
+
+// ビジュアライザ画面
+func handleVisualizer(w http.ResponseWriter, r *http.Request) {
+	gameId := r.URL.Query().Get("id")
+
+	if reloadTemplate {
+		visualizerTemplate = template.Must(template.ParseFiles("visualizer.html"))
+	}
+
+	_ = visualizerTemplate.Execute(w, map[string]interface{}{
+		"gameId": gameId,
+	})
+}
+
 // 表示情報更新用API
 func handleGetRefreshContent(w http.ResponseWriter, r *http.Request) {
 	commandTmp := getCommands()
@@ -642,7 +700,7 @@ func join() {
 			join, err := callJoin()
 			if err != nil {
 				setLastError(fmt.Sprintf("callJoin error: %s", err))
-			} else if join.Status != "ok" {
+			} else if join.Status != api.StatusOk {
 				setLastError(fmt.Sprintf("callJoin Status is not ok: %s", join.Status))
 			} else {
 				i := 0
@@ -760,6 +818,8 @@ func main() {
 	http.HandleFunc("/register", handleRegister)
 	http.HandleFunc("/readLog", handleReadLog)
 	http.HandleFunc("/viewLog", handleViewLog)
+	http.HandleFunc("/readReplay", handleReadReplay)
+	http.HandleFunc("/visualizer", handleVisualizer)
 	http.HandleFunc("/refresh", handleGetRefreshContent)
 	http.HandleFunc("/networkStatus", handleNetworkStatus)
 